@@ -2,8 +2,11 @@ package main
 
 import (
 	"archive/zip"
+	"bufio"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/kpango/glg"
@@ -13,7 +16,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Platform48/jellyfaas_cli/entities"
@@ -42,13 +48,29 @@ const p48CoreService = "https://api.jellyfaas.com/core-service/v1"
 const webUi = "https://app.jellyfaas.com/function/"
 
 const p48AuthService = "https://api.jellyfaas.com/auth-service/v1"
+const p48QueryService = "https://ai.jellyfaas.com/query-service/v1"
 const p48templatesRepo = "https://github.com/Platform48/jellyfaas_public_templates.git"
 const hiddenDataFile = ".jellyfaas"
 const jfApikeyHeader = "x-jf-apikey"
+const jfTokenHeader = "jfwt"
 const jellyfaasEndpoint = "https://api.jellyfaas.com/"
 
 const maxOpsLoops = 10
 
+const defaultBenchRequests = 100
+const benchHistogramWidth = 40
+
+const chatHistoryFile = "chat_history"
+const maxChatHistory = 500
+
+// libraryCacheFile is named per API key so switching keys never resolves against another account's library
+const libraryCacheFile = "library-%x.json"
+
+// errLibraryUnavailable means a function name could not be looked up because the library could not be loaded
+var errLibraryUnavailable = errors.New("cannot load the function library")
+
+const libraryCacheTTL = 5 * time.Minute
+
 const specfile = "jellyspec.json"
 
 const goTemplate = "go-template"
@@ -72,10 +94,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	if parser.Active.Name != "version" {
+	if parser.Active.Name != "version" && !machineOutput(parser, opts) {
 		color.Yellow("\nJellyFaaS CLI v" + version + " - http://app.jellyfaas.com \n\n")
 	}
 
+	//Commands that report success let scripts and CI see a failure in the exit code
+	ok := true
+
 	switch parser.Active.Name {
 	case "user":
 		switch parser.Active.Active.Name {
@@ -84,14 +109,61 @@ func main() {
 		case "delete":
 			deleteUser(opts.User.Delete.Email)
 		case "list":
-			listUsers()
+			listUsers(opts.User.List)
 		}
 	case "secret":
 		getApiKey()
 	case "library":
-		getLibrary(opts.Library.Details, opts.Library.ReadMe)
+		if parser.Active.Active == nil {
+			getLibrary(opts.Library.Details, opts.Library.ReadMe, opts.Library.Version)
+			break
+		}
+		switch parser.Active.Active.Name {
+		case "search":
+			searchLibrary(opts.Library.Search)
+		case "tags":
+			listLibraryTags()
+		case "diff":
+			diffLibraryVersions(opts.Library.Diff.Args.Id, opts.Library.Diff.From, opts.Library.Diff.To)
+		case "changelog":
+			showChangeLog(opts.Library.ChangeLog.Args.Id, opts.Library.ChangeLog.Output)
+		case "stats":
+			showLibraryStats(opts.Library.Stats)
+		case "samples":
+			showLanguageSample(opts.Library.Samples)
+		case "url":
+			ok = showFunctionUrl(opts.Library.Url.Args.Id, opts.Library.Url.Size)
+		case "snippet":
+			ok = showFunctionSnippet(opts.Library.Snippet)
+		}
 	case "deploy":
 		deployFunction(opts.Deploy.ZipFile, opts.Deploy.Wait)
+	case "publish":
+		publishFunctions(opts.Publish)
+	case "invoke":
+		ok = invokeFunction(opts.Invoke)
+	case "bench":
+		ok = runBench(opts.Bench)
+	case "query":
+		ok = queryFunction(opts.Query)
+	case "chat":
+		chat(opts.Chat)
+	case "contract":
+		switch parser.Active.Active.Name {
+		case "test":
+			ok = testContract(opts.Contract.Test.Args.Id, opts.Contract.Test.Size)
+		}
+	case "function":
+		switch parser.Active.Active.Name {
+		case "activate":
+			setActiveState(opts.Function.Activate.Args.Id, true, opts.Function.Activate.Yes)
+		case "deactivate":
+			setActiveState(opts.Function.Deactivate.Args.Id, false, opts.Function.Deactivate.Yes)
+		case "delete":
+			deleteFunction(opts.Function.Delete)
+		case "rollback":
+			rollbackFunction(opts.Function.Rollback.Args.Id, opts.Function.Rollback.To)
+		}
 	case "token":
 		getToken(opts.Token)
 	case "spec":
@@ -116,6 +188,33 @@ func main() {
 	default:
 		fmt.Println("Unknown command")
 	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// machineOutput is true when the command output is meant for scripts, so the banner must not be printed
+func machineOutput(parser *flags.Parser, opts entities.Options) bool {
+	if parser.Active.Name == "bench" {
+		return opts.Bench.Output == "json"
+	}
+	//Invoke keeps stdout for the response body, everything else goes to stderr
+	if parser.Active.Name == "invoke" {
+		return true
+	}
+	if parser.Active.Name == "query" {
+		return opts.Query.Output == "json" || opts.Query.Raw
+	}
+	if parser.Active.Name == "library" && parser.Active.Active != nil {
+		switch parser.Active.Active.Name {
+		case "url", "snippet":
+			return true
+		case "changelog":
+			return opts.Library.ChangeLog.Output == "json"
+		}
+	}
+	return false
 }
 
 func showVersion() {
@@ -153,9 +252,9 @@ func generateSpec(json string, raw bool, flat bool) {
 		fmt.Println(*outputSchema)
 		return
 	}
-	fmt.Println("Json Schema (basic):\n------------------------------\n")
+	fmt.Print("Json Schema (basic):\n------------------------------\n\n")
 	fmt.Println(*outputSchema)
-	fmt.Println("\n------------------------------\n")
+	fmt.Print("\n------------------------------\n\n")
 }
 
 func createUser(email string, username string) {
@@ -195,60 +294,129 @@ func getToken(opts entities.GetTokenCommand) {
 		return
 	}
 
+	tokenResponse, err := requestToken(configFile.APIKey)
+	if err != nil {
+		fmt.Println("\tAn error happened when attempting to get token:", err)
+		return
+	}
+	fmt.Printf("Token details:\n\n")
+	fmt.Printf("Token:\n%s\n\n", tokenResponse.Token)
+	fmt.Printf("Expiry: %s\n", tokenResponse.Expiry)
+}
+
+// requestToken swaps the API key for a JWT, which is what deployed functions expect
+func requestToken(apiKey string) (*entities.TokenResponse, error) {
 	var tokenResponse entities.TokenResponse
 	url := p48AuthService + "/validate"
 
-	request, err := req.NewClient().NewRequest().SetSuccessResult(&tokenResponse).SetHeader(jfApikeyHeader, configFile.APIKey).Get(url)
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&tokenResponse).SetHeader(jfApikeyHeader, apiKey).Get(url)
 	if err != nil {
-		fmt.Println("\tError calling out to service", err)
-		return
+		return nil, fmt.Errorf("error calling out to service: %v", err)
 	}
 
 	if request.StatusCode != 200 {
-		fmt.Println("\tAn error happened when attempting to get token!")
-		return
+		return nil, fmt.Errorf("auth service returned status %d", request.StatusCode)
 	}
-	fmt.Printf("Token details:\n\n")
-	fmt.Printf("Token:\n%s\n\n", tokenResponse.Token)
-	fmt.Printf("Expiry: %s\n", tokenResponse.Expiry)
+
+	return &tokenResponse, nil
 }
 
 func deleteUser(email string) {
 	fmt.Printf("Deleting user: %s \n", email)
 }
 
-func listUsers() {
+func listUsers(opts entities.ListUsersCommand) {
 
 	configFile, err := readP48KeyFile()
 	if err != nil {
 		return
 	}
 
+	createdAfter, err := entities.ParseDateFilter(opts.CreatedAfter)
+	if err != nil {
+		fmt.Println("\tError with --created-after:", err)
+		return
+	}
+	createdBefore, err := entities.ParseDateFilter(opts.CreatedBefore)
+	if err != nil {
+		fmt.Println("\tError with --created-before:", err)
+		return
+	}
+	if opts.Page < 1 {
+		fmt.Println("\t--page must be at least 1")
+		return
+	}
+	if opts.Limit < 0 {
+		fmt.Println("\t--limit cannot be negative")
+		return
+	}
+
 	var listUsersResponse entities.Entity
 	url := p48CoreService + "/entity"
 
-	request, err := req.NewClient().NewRequest().SetSuccessResult(&listUsersResponse).SetHeader(jfApikeyHeader, configFile.APIKey).Get(url)
+	//Filtering always happens here, so server side paging is only asked for when nothing is
+	//filtered out, otherwise a page from the service could hold users the filter would drop
+	filtering := opts.Filter != "" || opts.CreatedAfter != "" || opts.CreatedBefore != ""
+	serverPaging := opts.Limit > 0 && !filtering
+
+	request := req.NewClient().NewRequest().SetSuccessResult(&listUsersResponse).SetHeader(jfApikeyHeader, configFile.APIKey)
+	if serverPaging {
+		if opts.SortBy != "" {
+			request.SetQueryParam("sortBy", opts.SortBy)
+		}
+		request.SetQueryParam("page", fmt.Sprintf("%d", opts.Page))
+		request.SetQueryParam("limit", fmt.Sprintf("%d", opts.Limit))
+	}
+
+	response, err := request.Get(url)
 	if err != nil {
 		fmt.Println("\tError calling out to service", err)
 		return
 	}
 
-	if request.StatusCode != 200 {
+	if response.StatusCode != 200 {
 		fmt.Println("\tAn error happened when attempting to list users!")
 		return
 	}
 
+	users := entities.FilterUsers(listUsersResponse.Entities, opts.Filter, createdAfter, createdBefore)
+	entities.SortUsers(users, opts.SortBy)
+	total := len(users)
+
+	//Older services ignore page and limit and send everyone, which is paged here instead
+	pagedByServer := serverPaging && listUsersResponse.Page != nil && listUsersResponse.Total != nil && len(users) <= opts.Limit
+	if pagedByServer {
+		total = *listUsersResponse.Total
+	} else {
+		users = entities.PageUsers(users, opts.Page, opts.Limit)
+	}
+
+	pages := 0
+	if opts.Limit > 0 {
+		pages = (total + opts.Limit - 1) / opts.Limit
+		if pages < 1 {
+			pages = 1
+		}
+		if opts.Page > pages {
+			fmt.Printf("\tPage %d is out of range, there are %d page(s) of %d users\n", opts.Page, pages, total)
+			return
+		}
+	}
+
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 
 	t.AppendHeader(table.Row{"Name", "Email", "Created At", "Updated At"})
-	for _, user := range listUsersResponse.Entities {
+	for _, user := range users {
 		t.AppendRow([]interface{}{user.Name, user.Email, user.CreatedAt, user.UpdatedAt})
 
 	}
 	t.SetStyle(table.StyleColoredYellowWhiteOnBlack)
 	t.Render()
 
+	if opts.Limit > 0 {
+		fmt.Printf("\nPage %d of %d (%d users)\n", opts.Page, pages, total)
+	}
 }
 
 func getApiKey() {
@@ -325,6 +493,12 @@ func cleanBadBuilds(buildId string) {
 		return
 	}
 
+	buildId, err = resolveBuildId(configFile.APIKey, buildId)
+	if err != nil {
+		fmt.Println("\t" + err.Error())
+		return
+	}
+
 	url := p48CoreService + "/badbuilds"
 	var response entities.BadBuildCleanResponse
 
@@ -372,7 +546,7 @@ func checkIfFunctionExists(name string) {
 	fmt.Printf("Function %s exists: %t\n", name, response.Exists)
 }
 
-func getLibrary(details string, readme bool) {
+func getLibrary(details string, readme bool, version int) {
 
 	configFile, err := readP48KeyFile()
 	if err != nil {
@@ -381,25 +555,23 @@ func getLibrary(details string, readme bool) {
 
 	//Get the library
 	if details == "" {
-		url := p48CoreService + "/library"
-		var response entities.LibraryResponse
-
-		request, err := req.NewClient().NewRequest().SetSuccessResult(&response).SetHeader(jfApikeyHeader, configFile.APIKey).Get(url)
+		response, err := fetchLibrary(configFile.APIKey)
 		if err != nil {
-			fmt.Println("\tError calling out to service", err)
-			return
-		}
-
-		if request.StatusCode != 200 {
-			fmt.Println("\tAn error happened when attempting to get Secret Key!")
+			fmt.Println("\tError getting the library:", err)
 			return
 		}
 
 		t := table.NewWriter()
 		t.SetOutputMirror(os.Stdout)
-		t.AppendHeader(table.Row{"Name", "Id", "Owner", "Versions", "Created At", "Latest Change", "Description"})
+		inactive := color.New(color.FgRed, color.Bold).SprintFunc()
+
+		t.AppendHeader(table.Row{"Name", "Id", "Owner", "Status", "Versions", "Created At", "Latest Change", "Description"})
 		for _, library := range response.LibraryItem {
-			t.AppendRow([]interface{}{library.Name, library.FunctionId, library.Owner, library.Versions, library.CreatedAt.Format("01-01-2006"), library.LastRelease.Format("01-01-2006"), library.Description})
+			status := "active"
+			if library.Active != nil && !*library.Active {
+				status = inactive("INACTIVE")
+			}
+			t.AppendRow([]interface{}{library.Name, library.FunctionId, library.Owner, status, library.Versions, library.CreatedAt.Format("01-01-2006"), library.LastRelease.Format("01-01-2006"), library.Description})
 			//t.AppendSeparator()
 		}
 
@@ -426,26 +598,17 @@ func getLibrary(details string, readme bool) {
 		return
 	}
 
-	functionId := details
-
-	url := p48CoreService + "/library/" + functionId
-	var fd entities.LibraryItemDetailsResponse
-
-	request, err := req.NewClient().NewRequest().SetSuccessResult(&fd).SetHeader(jfApikeyHeader, configFile.APIKey).Get(url)
+	fd, err := lookupLibraryItem(configFile.APIKey, details)
 	if err != nil {
-		fmt.Println("\tError calling out to service", err)
-		return
-	}
-
-	if request.StatusCode != 200 {
-		fmt.Println("\tCannot find library item requested, is the name correct?")
+		printLookupError(err)
 		return
 	}
 
 	var readmePlaceHolder []byte
+	var changeLogPlaceHolder []byte
+	found := version == 0
 
 	greenBold := color.New(color.FgGreen, color.Underline).SprintFunc()
-	redBold := color.New(color.FgRed, color.Underline).SprintFunc()
 
 	fmt.Printf("%s %s\n", greenBold("Function Name:"), fd.Name)
 	fmt.Printf("%s %s\n", greenBold("Function ID:"), fd.FunctionId)
@@ -457,19 +620,26 @@ func getLibrary(details string, readme bool) {
 	fmt.Println(greenBold("Versions:"))
 	for _, v := range fd.Versions {
 
+		if version != 0 && v.Version != version {
+			continue
+		}
+		found = true
+
 		fmt.Printf("%s %s\n", greenBold("Description:"), v.Description)
 		fmt.Printf("%s %s\n", greenBold("Entry Point:"), v.EntryPoint)
 		fmt.Printf("  %s %d\n", greenBold("Version:"), v.Version)
 		fmt.Printf("  %s %t\n", greenBold("Latest:"), v.Latest)
-		for _, s := range v.Sizes {
+		for _, s := range entities.SortSizes(v.Sizes) {
+			fmt.Printf("    %s %s\n", greenBold("Size:"), s.Size)
 			fmt.Printf("    %s %s\n", greenBold("FunctionId:"), s.FunctionId)
 			fmt.Printf("    %s %s\n", greenBold("Function URL:"), webUi+fd.FunctionId)
-			fmt.Printf("    %s %s\n", greenBold("URL:"), jellyfaasEndpoint+s.FunctionId+"/"+fd.FunctionId)
+			fmt.Printf("    %s %s\n", greenBold("URL:"), functionUrl(fd.FunctionId, s))
 		}
 		fmt.Printf("  %s %s\n", greenBold("Release Date:"), v.ReleaseDate.Format(time.RFC1123))
 		fmt.Printf("  %s %s\n", greenBold("Runtime:"), v.Runtime)
 
-		if v.Latest {
+		//Only the latest version is shown in full, unless a version was asked for
+		if (version == 0 && v.Latest) || v.Version == version {
 			if v.ReadMeFileEncoded != "" {
 				fmt.Printf("  %s %s\n", greenBold("Readme File:"), "Found")
 				readmePlaceHolder, err = base64.StdEncoding.DecodeString(v.ReadMeFileEncoded)
@@ -479,225 +649,2792 @@ func getLibrary(details string, readme bool) {
 
 			if v.ChangeLogFileEncoded != "" {
 				fmt.Printf("  %s %s\n", greenBold("ChangeLog File:"), "found")
+				changeLogPlaceHolder, err = base64.StdEncoding.DecodeString(v.ChangeLogFileEncoded)
 			} else {
 				fmt.Printf("  %s %s\n", greenBold("ChangeLog File:"), "Not found")
 			}
 			fmt.Println()
-			fmt.Println("  " + greenBold("Requirements:"))
-			fmt.Printf("    %s %s\n", greenBold("Request Type:"), v.Requirements.RequestType)
-			if v.Requirements.InputType != nil {
-				fmt.Printf("    %s %s\n", greenBold("Input Type:"), *v.Requirements.InputType)
-			}
-			for _, p := range v.Requirements.QueryParams {
-				fmt.Printf("     %s %s, Required: %v\n", greenBold("Query Param:"), p.Name, p.Required)
-			}
+			displayRequirements(v.Requirements)
+		}
+		fmt.Println()
+	}
 
-			if v.Requirements.InputJsonSchemaEncoded != nil {
-				encodedSchema, err := base64.StdEncoding.DecodeString(*v.Requirements.InputJsonSchemaEncoded)
-				if err != nil {
-					fmt.Println("    " + redBold("Input Schema: Error getting schema, please contact support"))
-				}
-				fmt.Println("    "+greenBold("Input Schema:"), string(encodedSchema))
+	if !found {
+		fmt.Printf("\tVersion %d not found, available versions: %s\n", version, availableVersions(fd))
+		return
+	}
 
-				if v.Requirements.InputJsonExample != nil {
-					decoded, err := base64.StdEncoding.DecodeString(*v.Requirements.InputJsonExample)
-					if err != nil {
-						fmt.Println("    " + redBold("Input JSON Example: Error getting example data, please contact support"))
-					}
-					fmt.Println("    "+greenBold("Input JSON Example:"), string(decoded))
-				}
-			}
+	//A specific version always shows its readme and changelog, the latest only on request
+	if readmePlaceHolder != nil && (readme || version != 0) {
+		output, err := mdToANSI(string(readmePlaceHolder))
+		if err == nil {
+			fmt.Println()
+			fmt.Println(output)
+		}
+	}
 
-			if v.Requirements.InputFileSchema != nil {
-				var fileSchema entities.FileSchema
-				err := json.Unmarshal(*v.Requirements.InputFileSchema, &fileSchema)
-				if err != nil {
-					fmt.Println("    " + greenBold("Input File Schema: Error getting schema, please contact support"))
-				}
+	if changeLogPlaceHolder != nil && version != 0 {
+		output, err := mdToANSI(string(changeLogPlaceHolder))
+		if err == nil {
+			fmt.Println()
+			fmt.Println(output)
+		}
+	}
 
-				fmt.Println("    "+greenBold("Input File Description:"), fileSchema.Description)
-				fmt.Println("    "+greenBold("Input File Required:"), fileSchema.Required)
-				array := strings.Join(fileSchema.Extensions, ", ")
-				fmt.Println("    "+greenBold("Input File Extensions:"), array)
-			}
+	return
 
-			if v.Requirements.OutputJsonSchemaEncoded != nil {
-				encodedSchema, err := base64.StdEncoding.DecodeString(*v.Requirements.OutputJsonSchemaEncoded)
-				if err != nil {
-					fmt.Println("    " + redBold("Error decoding output JSON schema"))
-				}
-				fmt.Println("    "+greenBold("Output Schema:"), string(encodedSchema))
+}
 
-				if *v.Requirements.OutputJsonExample != "" {
-					fmt.Println("    "+greenBold("Output JSON Example:"), string(*v.Requirements.OutputJsonExample))
-				}
-				//encodedOutputExample, err := base64.StdEncoding.DecodeString(*v.Requirements.OutputJsonExample)
-				//if err != nil {
-				//	fmt.Println("    " + redBold("Error decoding output example JSON schema"))
-				//}
+func displayRequirements(requirements entities.FunctionRequirement) {
+	greenBold := color.New(color.FgGreen, color.Underline).SprintFunc()
+	redBold := color.New(color.FgRed, color.Underline).SprintFunc()
 
-			}
+	fmt.Println("  " + greenBold("Requirements:"))
+	fmt.Printf("    %s %s\n", greenBold("Request Type:"), requirements.RequestType)
+	if requirements.InputType != nil {
+		fmt.Printf("    %s %s\n", greenBold("Input Type:"), *requirements.InputType)
+	}
+	if requirements.OutputType != nil {
+		fmt.Printf("    %s %s\n", greenBold("Output Type:"), *requirements.OutputType)
+	}
+	for _, p := range requirements.QueryParams {
+		fmt.Printf("     %s %s, Required: %v\n", greenBold("Query Param:"), p.Name, p.Required)
+		if p.Description != "" {
+			fmt.Printf("       %s %s\n", greenBold("Description:"), p.Description)
+		}
+		if p.ExampleData != "" {
+			fmt.Printf("       %s %s\n", greenBold("Example:"), p.ExampleData)
+		}
+	}
 
-			if v.Requirements.OutputFileSchema != nil {
-				var fileSchema entities.FileSchema
-				err := json.Unmarshal(*v.Requirements.OutputFileSchema, &fileSchema)
-				if err != nil {
-					fmt.Println("    " + greenBold("Input Output Schema: Error getting schema, please contact support"))
-				}
+	if requirements.InputJsonSchemaEncoded != nil {
+		encodedSchema, err := base64.StdEncoding.DecodeString(*requirements.InputJsonSchemaEncoded)
+		if err != nil {
+			fmt.Println("    " + redBold("Input Schema: Error getting schema, please contact support"))
+		}
+		fmt.Println("    "+greenBold("Input Schema:"), string(encodedSchema))
 
-				fmt.Println("    "+greenBold("Output File Description:"), fileSchema.Description)
-				array := strings.Join(fileSchema.Extensions, ", ")
-				fmt.Println("    "+greenBold("Output File Extensions:"), array)
+		if requirements.InputJsonExample != nil {
+			decoded, err := base64.StdEncoding.DecodeString(*requirements.InputJsonExample)
+			if err != nil {
+				fmt.Println("    " + redBold("Input JSON Example: Error getting example data, please contact support"))
 			}
+			fmt.Println("    "+greenBold("Input JSON Example:"), string(decoded))
 		}
-		fmt.Println()
 	}
 
-	if readmePlaceHolder != nil && readme {
-		output, err := mdToANSI(string(readmePlaceHolder))
-		if err == nil {
-			fmt.Println()
-			fmt.Println(output)
+	if requirements.InputFileSchema != nil {
+		var fileSchema entities.FileSchema
+		err := json.Unmarshal(*requirements.InputFileSchema, &fileSchema)
+		if err != nil {
+			fmt.Println("    " + greenBold("Input File Schema: Error getting schema, please contact support"))
 		}
+
+		fmt.Println("    "+greenBold("Input File Description:"), fileSchema.Description)
+		fmt.Println("    "+greenBold("Input File Required:"), fileSchema.Required)
+		array := strings.Join(fileSchema.Extensions, ", ")
+		fmt.Println("    "+greenBold("Input File Extensions:"), array)
 	}
 
-	return
+	if requirements.OutputJsonSchemaEncoded != nil {
+		encodedSchema, err := base64.StdEncoding.DecodeString(*requirements.OutputJsonSchemaEncoded)
+		if err != nil {
+			fmt.Println("    " + redBold("Error decoding output JSON schema"))
+		}
+		fmt.Println("    "+greenBold("Output Schema:"), string(encodedSchema))
 
-}
+		if requirements.OutputJsonExample != nil && *requirements.OutputJsonExample != "" {
+			fmt.Println("    "+greenBold("Output JSON Example:"), string(*requirements.OutputJsonExample))
+		}
+	}
 
-func deployFunction(filename string, wait bool) {
+	if requirements.OutputFileSchema != nil {
+		var fileSchema entities.FileSchema
+		err := json.Unmarshal(*requirements.OutputFileSchema, &fileSchema)
+		if err != nil {
+			fmt.Println("    " + greenBold("Input Output Schema: Error getting schema, please contact support"))
+		}
+
+		fmt.Println("    "+greenBold("Output File Description:"), fileSchema.Description)
+		array := strings.Join(fileSchema.Extensions, ", ")
+		fmt.Println("    "+greenBold("Output File Extensions:"), array)
+	}
+}
 
+func diffLibraryVersions(functionId string, from int, to int) {
 	configFile, err := readP48KeyFile()
 	if err != nil {
 		return
 	}
 
-	fmt.Println("\n\tDeploying function " + filename)
-
-	var functionResponse entities.DeployedFunctionResponse
-	var errorDetails entities.ErrorDetails
-	url := p48CoreService + "/upload"
-
-	request, err := req.NewClient().NewRequest().SetSuccessResult(&functionResponse).SetErrorResult(&errorDetails).SetFile("file", filename).SetHeader(jfApikeyHeader, configFile.APIKey).Post(url)
+	fd, err := lookupLibraryItem(configFile.APIKey, functionId)
 	if err != nil {
-		fmt.Println("\tError calling out to backend deployment service")
+		printLookupError(err)
 		return
 	}
 
-	if request.StatusCode != 200 {
-		fmt.Println("\tAn error happened when attempting to get deploy, this normally happens when an upgrade is already in progress or another error")
-		fmt.Printf("\tSupport ID: %s \nError: %s\n", errorDetails.ErrorId, errorDetails.ErrorMessage)
+	fromVersion := findVersion(fd, from)
+	toVersion := findVersion(fd, to)
+	if fromVersion == nil || toVersion == nil {
+		fmt.Printf("\tVersion not found, available versions: %s\n", availableVersions(fd))
 		return
 	}
 
-	for _, v := range functionResponse.DeployedDetails {
-		functionName := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
-		fmt.Printf("\tFunction URL: %s%s\n", webUi, functionName)
-		fmt.Printf("\tAPI Endpoint: %s\n", v.FunctionUrl)
+	changes, err := entities.DiffRequirements(fromVersion.Requirements, toVersion.Requirements)
+	if err != nil {
+		fmt.Println("\tError comparing versions:", err)
+		return
 	}
 
-	if functionResponse.New {
-		fmt.Println("\tFunction is a new function, and is currently deploying.")
-	} else {
-		fmt.Printf("\tFunction upgrading, current version is: %d, new version will be %d \n\n", functionResponse.CurrentVersion, functionResponse.DeployingVersion)
-	}
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+	redBold := color.New(color.FgRed, color.Bold).SprintFunc()
 
-	if wait {
-		fmt.Println("Waiting for function to be ready..")
+	fmt.Printf("Contract changes for %s, version %d -> %d\n\n", fd.Name, from, to)
 
-		var opIds []string
-		for _, v := range functionResponse.DeployedDetails {
-			opIds = append(opIds, v.Opid)
+	if len(changes) == 0 {
+		fmt.Println(greenBold("No contract changes, callers can upgrade safely."))
+		return
+	}
+
+	var breaking, additive []entities.ContractChange
+	for _, c := range changes {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		} else {
+			additive = append(additive, c)
 		}
+	}
 
-		checkIfDeployedSuccessfully(functionResponse.FunctionId, opIds, configFile.APIKey)
+	if len(breaking) > 0 {
+		fmt.Println(redBold(fmt.Sprintf("Breaking changes (%d):", len(breaking))))
+		for _, c := range breaking {
+			fmt.Printf("  - %s: %s\n", c.Path, c.Description)
+		}
+		fmt.Println()
 	}
 
+	if len(additive) > 0 {
+		fmt.Println(greenBold(fmt.Sprintf("Additive changes (%d):", len(additive))))
+		for _, c := range additive {
+			fmt.Printf("  + %s: %s\n", c.Path, c.Description)
+		}
+	}
 }
 
-func setPublishedState(id string, state bool) {
+func showChangeLog(functionId string, output string) {
 	configFile, err := readP48KeyFile()
 	if err != nil {
 		return
 	}
 
-	var url = p48CoreService
-
-	if state {
-		url = url + "/library/publish/" + id
-	} else {
-		url = url + "/library/withdraw/" + id
+	fd, err := lookupLibraryItem(configFile.APIKey, functionId)
+	if err != nil {
+		printLookupError(err)
+		return
 	}
-	var response = entities.LibraryItemDetailsResponse{}
 
-	request, err := req.NewClient().NewRequest().SetSuccessResult(&response).SetHeader(jfApikeyHeader, configFile.APIKey).Put(url)
+	entries, err := entities.BuildChangeLog(fd.Versions)
 	if err != nil {
-		fmt.Println("\tError calling out to service", err)
+		fmt.Println("\tError reading changelog:", err)
 		return
 	}
 
-	if request.StatusCode != 200 {
-		fmt.Println("\tAn error happened when attempting to publish function")
+	if output == "json" {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Println("\tError formatting changelog:", err)
+			return
+		}
+		fmt.Println(string(out))
 		return
 	}
 
-	fmt.Printf("\tFunction published successfully\n")
+	rendered, err := mdToANSI(entities.ChangeLogMarkdown(fd.Name, entries))
+	if err != nil {
+		fmt.Println("\tError rendering changelog:", err)
+		return
+	}
+	fmt.Println(rendered)
 }
 
-func checkIfDeployedSuccessfully(functionId string, opsLink []string, key string) {
-
-	type opsLinkStatus struct {
-		Complete   bool
-		opsUrl     string
-		opsLink    string
-		functionId string
+func showLanguageSample(opts entities.LibrarySamplesCommand) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
 	}
 
-	var opsLinkStatuses []opsLinkStatus
-
-	for _, v := range opsLink {
-		opsLinkStatuses = append(opsLinkStatuses, opsLinkStatus{Complete: false, opsLink: p48CoreService + "/upload/" + v + "/" + functionId})
+	fd, err := lookupLibraryItem(configFile.APIKey, opts.Args.Id)
+	if err != nil {
+		printLookupError(err)
+		return
 	}
 
-	client := req.NewClient()
-
-	for i := 1; i < maxOpsLoops+1; i++ {
-		for index, v := range opsLinkStatuses {
-
-			if v.Complete {
-				continue
-			}
+	v := findVersionOrLatest(fd, opts.Version)
+	if v == nil {
+		fmt.Printf("\tVersion not found, available versions: %s\n", availableVersions(fd))
+		return
+	}
 
-			ops := Operations{}
+	var languages []string
+	for _, sample := range v.LanguageSamples {
+		languages = append(languages, sample.Language)
+	}
 
-			request, err := client.NewRequest().SetSuccessResult(&ops).SetHeader(jfApikeyHeader, key).Get(v.opsLink)
-			if err != nil || request.StatusCode != 200 {
-				fmt.Println("\tError calling backend service to validate status", err)
-				return
-			}
+	if len(languages) == 0 {
+		fmt.Printf("\tNo code samples available for version %d\n", v.Version)
+		return
+	}
 
-			if ops.Status == "DEPLOYED" {
-				opsLinkStatuses[index].Complete = true
-			} else {
-				fmt.Printf("Count %d/%d : Operation is not complete, waiting for function to be ready, status : %s\n", i, maxOpsLoops, ops.Status)
-			}
+	if opts.Language == "" {
+		fmt.Printf("Available languages for version %d: %s\n", v.Version, strings.Join(languages, ", "))
+		return
+	}
 
+	var sample *entities.LanguageSamples
+	for i := range v.LanguageSamples {
+		if normaliseLanguage(v.LanguageSamples[i].Language) == normaliseLanguage(opts.Language) {
+			sample = &v.LanguageSamples[i]
+			break
 		}
+	}
 
-		//Check if all operations are complete
-		allComplete := true
-		for _, v := range opsLinkStatuses {
-			if !v.Complete {
-				allComplete = false
-			}
-		}
+	if sample == nil {
+		fmt.Printf("\tNo sample for language %s, available languages: %s\n", opts.Language, strings.Join(languages, ", "))
+		return
+	}
 
-		if allComplete {
-			fmt.Println("\n\tOperation is complete, function(s) is ready to be used!")
+	if opts.Write != "" {
+		if err := os.WriteFile(opts.Write, []byte(sample.SdkCode), 0644); err != nil {
+			fmt.Println("\tError writing sample:", err)
 			return
 		}
+		fmt.Printf("Sample for %s (version %d) written to %s\n", sample.Language, v.Version, opts.Write)
+		return
+	}
+
+	var md strings.Builder
+	md.WriteString(fmt.Sprintf("# %s - %s sample (version %d)\n\n", fd.Name, sample.Language, v.Version))
+	if sample.Params != "" {
+		md.WriteString("## Params\n\n```\n" + sample.Params + "\n```\n\n")
+	}
+	if sample.Input != "" {
+		md.WriteString("## Input\n\n```json\n" + sample.Input + "\n```\n\n")
+	}
+	if sample.Output != "" {
+		md.WriteString("## Output\n\n```json\n" + sample.Output + "\n```\n\n")
+	}
+	md.WriteString("## Code\n\n```" + normaliseLanguage(sample.Language) + "\n" + sample.SdkCode + "\n```\n")
+
+	rendered, err := mdToANSI(md.String())
+	if err != nil {
+		fmt.Println(sample.SdkCode)
+		return
+	}
+	fmt.Println(rendered)
+}
+
+func showFunctionUrl(ref string, size string) bool {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
+
+	target, err := resolveInvokeTarget(configFile.APIKey, ref, size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	fmt.Println(target.url)
+	return true
+}
+
+func showFunctionSnippet(opts entities.LibrarySnippetCommand) bool {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
+
+	target, err := resolveInvokeTarget(configFile.APIKey, opts.Args.Id, opts.Size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	body, query, err := examplePayload(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	request := entities.SnippetRequest{
+		Method:      target.method,
+		Url:         target.url,
+		Query:       query,
+		Body:        body,
+		FileInput:   isFileType(target.version.Requirements.InputType),
+		TokenHeader: jfTokenHeader,
+	}
+
+	if opts.WithToken {
+		token, err := requestToken(configFile.APIKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tAn error happened when attempting to get token:", err)
+			return false
+		}
+		request.Token = token.Token
+	}
+
+	snippet, err := entities.Snippet(opts.As, request)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+	fmt.Print(snippet)
+	return true
+}
+
+// normaliseLanguage maps the language aliases accepted by the CLI onto a single name,
+// which is also the name the syntax highlighter knows the language by.
+func normaliseLanguage(language string) string {
+	switch strings.ToLower(language) {
+	case "go", "golang":
+		return "go"
+	case "nodejs", "javascript", "js", "node.js", "node":
+		return "javascript"
+	case "typescript", "ts":
+		return "typescript"
+	case "python", "py":
+		return "python"
+	case "dotnet", "csharp", "c#", "dn":
+		return "csharp"
+	default:
+		return strings.ToLower(language)
+	}
+}
+
+// findVersionOrLatest returns the requested version, or the latest when version is 0
+func findVersionOrLatest(fd *entities.LibraryItemDetailsResponse, version int) *entities.VersionDetailsResponse {
+	if version != 0 {
+		return findVersion(fd, version)
+	}
+	for i := range fd.Versions {
+		if fd.Versions[i].Latest {
+			return &fd.Versions[i]
+		}
+	}
+	return nil
+}
+
+func findVersion(fd *entities.LibraryItemDetailsResponse, version int) *entities.VersionDetailsResponse {
+	for i := range fd.Versions {
+		if fd.Versions[i].Version == version {
+			return &fd.Versions[i]
+		}
+	}
+	return nil
+}
+
+func availableVersions(fd *entities.LibraryItemDetailsResponse) string {
+	var available []string
+	for _, v := range fd.Versions {
+		available = append(available, fmt.Sprintf("%d", v.Version))
+	}
+	return strings.Join(available, ", ")
+}
+
+func fetchLibraryItem(apiKey string, functionId string) (*entities.LibraryItemDetailsResponse, error) {
+	url := p48CoreService + "/library/" + functionId
+	var fd entities.LibraryItemDetailsResponse
+
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&fd).SetHeader(jfApikeyHeader, apiKey).Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error calling out to service: %v", err)
+	}
+
+	if request.StatusCode != 200 {
+		return nil, fmt.Errorf("service returned status %d", request.StatusCode)
+	}
+
+	return &fd, nil
+}
+
+func fetchLibrary(apiKey string) (*entities.LibraryResponse, error) {
+	url := p48CoreService + "/library"
+	var response entities.LibraryResponse
+
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&response).SetHeader(jfApikeyHeader, apiKey).Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error calling out to service: %v", err)
+	}
+
+	if request.StatusCode != 200 {
+		return nil, fmt.Errorf("service returned status %d", request.StatusCode)
+	}
+
+	writeLibraryCache(apiKey, &response)
+
+	return &response, nil
+}
+
+// fetchLibraryCached returns the library from the local cache if it is fresh enough, this keeps
+// name lookups fast without hitting the service for every command.
+func fetchLibraryCached(apiKey string) (*entities.LibraryResponse, bool, error) {
+	cacheFile, err := getLibraryCacheLocation(apiKey)
+	if err == nil {
+		if info, err := os.Stat(cacheFile); err == nil && time.Since(info.ModTime()) < libraryCacheTTL {
+			buf, err := os.ReadFile(cacheFile)
+			if err == nil {
+				var response entities.LibraryResponse
+				if err := json.Unmarshal(buf, &response); err == nil {
+					return &response, true, nil
+				}
+			}
+		}
+	}
+
+	response, err := fetchLibrary(apiKey)
+	return response, false, err
+}
+
+func writeLibraryCache(apiKey string, response *entities.LibraryResponse) {
+	cacheFile, err := getLibraryCacheLocation(apiKey)
+	if err != nil {
+		return
+	}
+
+	buf, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+		return
+	}
+	_ = os.WriteFile(cacheFile, buf, 0600)
+}
+
+func clearLibraryCache(apiKey string) {
+	cacheFile, err := getLibraryCacheLocation(apiKey)
+	if err != nil {
+		return
+	}
+	_ = os.Remove(cacheFile)
+}
+
+func getLibraryCacheLocation(apiKey string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(apiKey))
+	return filepath.Join(cacheDir, "jellyfaas", fmt.Sprintf(libraryCacheFile, key[:8])), nil
+}
+
+// resolveFunctionId turns a function ID, name or unique prefix into a function ID. Anything not
+// found in the library is passed through as is and left to the service to reject, but a library
+// that cannot be loaded is an error so auth and network problems are not hidden.
+func resolveFunctionId(apiKey string, ref string) (string, error) {
+	library, cached, err := fetchLibraryCached(apiKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errLibraryUnavailable, err)
+	}
+
+	item, err := entities.ResolveFunction(library.LibraryItem, ref)
+	if err != nil {
+		return "", err
+	}
+
+	//The cache may be older than the function, try once more with a fresh library
+	if item == nil && cached {
+		library, err = fetchLibrary(apiKey)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", errLibraryUnavailable, err)
+		}
+		item, err = entities.ResolveFunction(library.LibraryItem, ref)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if item == nil {
+		return ref, nil
+	}
+	return item.FunctionId, nil
+}
+
+// lookupLibraryItem resolves ref to a function and fetches its details
+func lookupLibraryItem(apiKey string, ref string) (*entities.LibraryItemDetailsResponse, error) {
+	functionId, err := resolveFunctionId(apiKey, ref)
+	if err != nil {
+		return nil, err
+	}
+	return fetchLibraryItem(apiKey, functionId)
+}
+
+func printLookupError(err error) {
+	var ambiguous *entities.AmbiguousFunctionError
+	if errors.As(err, &ambiguous) || errors.Is(err, errLibraryUnavailable) {
+		fmt.Println("\t" + err.Error())
+		return
+	}
+	fmt.Println("\tCannot find library item requested, is the name correct?")
+}
+
+// resolveBuildId accepts a build ID, a unique prefix of one, or the name or function ID of a bad build
+func resolveBuildId(apiKey string, ref string) (string, error) {
+	url := p48CoreService + "/badbuilds"
+	var response entities.BadBuildResponse
+
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&response).SetHeader(jfApikeyHeader, apiKey).Get(url)
+	if err != nil || request.StatusCode != 200 {
+		return ref, nil
+	}
+
+	matchers := []func(entities.BadBuildsItemResponse) bool{
+		func(b entities.BadBuildsItemResponse) bool { return b.BuildId == ref },
+		func(b entities.BadBuildsItemResponse) bool {
+			return b.FunctionId == ref || strings.EqualFold(b.Name, ref)
+		},
+		func(b entities.BadBuildsItemResponse) bool { return strings.HasPrefix(b.BuildId, ref) },
+	}
+
+	for _, match := range matchers {
+		var matches []string
+		for _, b := range response.BadBuilds {
+			if match(b) {
+				matches = append(matches, b.BuildId)
+			}
+		}
+
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+		if len(matches) > 1 {
+			return "", fmt.Errorf("%q is ambiguous, it matches builds: %s", ref, strings.Join(matches, ", "))
+		}
+	}
+
+	return ref, nil
+}
+
+func searchLibrary(opts entities.LibrarySearchCommand) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	response, err := fetchLibrary(configFile.APIKey)
+	if err != nil {
+		fmt.Println("\tError getting the library:", err)
+		return
+	}
+
+	results := entities.SearchLibrary(response.LibraryItem, opts.Args.Terms, entities.LibrarySearchFilter{
+		Tags:      opts.Tags,
+		Owner:     opts.Owner,
+		Ai:        opts.Ai,
+		Published: opts.Published,
+	})
+
+	if len(results) == 0 {
+		fmt.Println("No library items matched your search")
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Score", "Name", "Id", "Owner", "Tags", "AI", "Description"})
+	for _, r := range results {
+		t.AppendRow([]interface{}{r.Score, r.Item.Name, r.Item.FunctionId, r.Item.Owner, strings.Join(r.Item.Tags, ", "), r.Item.Ai, r.Item.Description})
+	}
+
+	t.SetStyle(table.StyleColoredYellowWhiteOnBlack)
+	t.Render()
+
+	fmt.Printf("\n%d matching item(s)\n", len(results))
+}
+
+func listLibraryTags() {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	response, err := fetchLibrary(configFile.APIKey)
+	if err != nil {
+		fmt.Println("\tError getting the library:", err)
+		return
+	}
+
+	tags := entities.CountLibraryTags(*response)
+	if len(tags) == 0 {
+		fmt.Println("No tags found in the library")
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Tag", "Items"})
+	for _, tag := range tags {
+		t.AppendRow([]interface{}{tag.Tag, tag.Count})
+	}
+
+	t.SetStyle(table.StyleColoredYellowWhiteOnBlack)
+	t.Render()
+}
+
+func showLibraryStats(opts entities.LibraryStatsCommand) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	response, err := fetchLibrary(configFile.APIKey)
+	if err != nil {
+		fmt.Println("\tError getting the library:", err)
+		return
+	}
+
+	functionId := ""
+	if opts.Args.Id != "" {
+		functionId, err = resolveFunctionId(configFile.APIKey, opts.Args.Id)
+		if err != nil {
+			printLookupError(err)
+			return
+		}
+	}
+
+	type statsRow struct {
+		name        string
+		functionId  string
+		size        string
+		invocations int
+		avgMs       int64
+	}
+
+	var rows []statsRow
+	for _, item := range response.LibraryItem {
+		if functionId != "" && item.FunctionId != functionId {
+			continue
+		}
+		if item.Sizes == nil {
+			continue
+		}
+		for _, s := range *item.Sizes {
+			//The service reports the average response time in milliseconds
+			rows = append(rows, statsRow{name: item.Name, functionId: item.FunctionId, size: s.Size, invocations: s.InvocationCount, avgMs: int64(s.AvgResponseTimeMs)})
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No usage stats found")
+		return
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch opts.SortBy {
+		case "latency":
+			return rows[i].avgMs > rows[j].avgMs
+		case "name":
+			if rows[i].name != rows[j].name {
+				return strings.ToLower(rows[i].name) < strings.ToLower(rows[j].name)
+			}
+			return rows[i].size < rows[j].size
+		default:
+			return rows[i].invocations > rows[j].invocations
+		}
+	})
+
+	//Totals cover every row, not just the top N shown
+	var totalInvocations int
+	var totalMs int64
+	for _, r := range rows {
+		totalInvocations += r.invocations
+		totalMs += r.avgMs * int64(r.invocations)
+	}
+
+	var overallAvg int64
+	if totalInvocations > 0 {
+		overallAvg = totalMs / int64(totalInvocations)
+	}
+
+	totalLabel := "Total"
+	if opts.Top > 0 && opts.Top < len(rows) {
+		totalLabel = fmt.Sprintf("Total (all %d rows)", len(rows))
+		rows = rows[:opts.Top]
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Name", "Id", "Size", "Invocations", "Avg Response (ms)"})
+	for _, r := range rows {
+		t.AppendRow([]interface{}{r.name, r.functionId, r.size, r.invocations, r.avgMs})
+	}
+	t.AppendFooter(table.Row{totalLabel, "", "", totalInvocations, overallAvg})
+
+	t.SetStyle(table.StyleColoredYellowWhiteOnBlack)
+	t.Render()
+}
+
+func deployFunction(filename string, wait bool) {
+
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	fmt.Println("\n\tDeploying function " + filename)
+
+	var functionResponse entities.DeployedFunctionResponse
+	var errorDetails entities.ErrorDetails
+	url := p48CoreService + "/upload"
+
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&functionResponse).SetErrorResult(&errorDetails).SetFile("file", filename).SetHeader(jfApikeyHeader, configFile.APIKey).Post(url)
+	if err != nil {
+		fmt.Println("\tError calling out to backend deployment service")
+		return
+	}
+
+	if request.StatusCode != 200 {
+		fmt.Println("\tAn error happened when attempting to get deploy, this normally happens when an upgrade is already in progress or another error")
+		fmt.Printf("\tSupport ID: %s \nError: %s\n", errorDetails.ErrorId, errorDetails.ErrorMessage)
+		return
+	}
+
+	for _, v := range functionResponse.DeployedDetails {
+		functionName := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		fmt.Printf("\tFunction URL: %s%s\n", webUi, functionName)
+		fmt.Printf("\tAPI Endpoint: %s\n", v.FunctionUrl)
+	}
+
+	if functionResponse.New {
+		fmt.Println("\tFunction is a new function, and is currently deploying.")
+	} else {
+		fmt.Printf("\tFunction upgrading, current version is: %d, new version will be %d \n\n", functionResponse.CurrentVersion, functionResponse.DeployingVersion)
+	}
+
+	if wait {
+		fmt.Println("Waiting for function to be ready..")
+
+		var opIds []string
+		for _, v := range functionResponse.DeployedDetails {
+			opIds = append(opIds, v.Opid)
+		}
+
+		checkIfDeployedSuccessfully(functionResponse.FunctionId, opIds, configFile.APIKey)
+	}
+
+}
+
+func publishFunctions(opts entities.PublishCommands) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	ids := append([]string{}, opts.IDs...)
+	ids = append(ids, opts.Args.IDs...)
+
+	//Select every library item carrying one of the tags
+	if len(opts.Tags) > 0 {
+		response, err := fetchLibrary(configFile.APIKey)
+		if err != nil {
+			fmt.Println("\tError getting the library:", err)
+			return
+		}
+
+		//One search per tag, as a search filter needs every tag to match
+		selected := 0
+		for _, tag := range opts.Tags {
+			results := entities.SearchLibrary(response.LibraryItem, nil, entities.LibrarySearchFilter{Tags: []string{tag}})
+			for _, r := range results {
+				ids = append(ids, r.Item.FunctionId)
+			}
+			selected += len(results)
+		}
+
+		if selected == 0 {
+			fmt.Printf("\tNo library items are tagged %s\n", strings.Join(opts.Tags, " or "))
+			if len(ids) == 0 {
+				return
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("\tNo functions selected, supply one or more IDs or a --tag")
+		return
+	}
+
+	state := !opts.Withdraw
+	action := "published"
+	if !state {
+		action = "withdrawn"
+	}
+
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+	redBold := color.New(color.FgRed, color.Bold).SprintFunc()
+
+	seen := map[string]bool{}
+	var published, failed int
+	for _, id := range ids {
+		functionId, err := resolveFunctionId(configFile.APIKey, id)
+		if err != nil {
+			fmt.Printf("  %s %v\n", redBold("Failed:"), err)
+			failed++
+			continue
+		}
+
+		if seen[functionId] {
+			continue
+		}
+		seen[functionId] = true
+
+		if err := setPublishedState(configFile.APIKey, functionId, state); err != nil {
+			fmt.Printf("  %s %s: %v\n", redBold("Failed:"), functionId, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  %s %s %s\n", greenBold("OK:"), functionId, action)
+		published++
+	}
+
+	fmt.Printf("\n%d function(s) %s, %d failed\n", published, action, failed)
+}
+
+func setPublishedState(apiKey string, id string, state bool) error {
+	var url = p48CoreService
+
+	if state {
+		url = url + "/library/publish/" + id
+	} else {
+		url = url + "/library/withdraw/" + id
+	}
+	var response = entities.LibraryItemDetailsResponse{}
+
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&response).SetHeader(jfApikeyHeader, apiKey).Put(url)
+	if err != nil {
+		return fmt.Errorf("error calling out to service: %v", err)
+	}
+
+	if request.StatusCode != 200 {
+		return fmt.Errorf("service returned status %d", request.StatusCode)
+	}
+
+	return nil
+}
+
+func setActiveState(ref string, state bool, yes bool) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	fd, err := lookupLibraryItem(configFile.APIKey, ref)
+	if err != nil {
+		printLookupError(err)
+		return
+	}
+
+	action := "activate"
+	if !state {
+		action = "deactivate"
+	}
+
+	if fd.Active == state {
+		fmt.Printf("\tFunction %s (%s) is already %sd\n", fd.Name, fd.FunctionId, action)
+		return
+	}
+
+	if !yes && !confirm(fmt.Sprintf("Are you sure you want to %s function %s (%s)?", action, fd.Name, fd.FunctionId)) {
+		fmt.Println("\tCancelled")
+		return
+	}
+
+	var url = p48CoreService + "/library/" + action + "/" + fd.FunctionId
+	var response = entities.LibraryItemDetailsResponse{}
+
+	request, err := req.NewClient().NewRequest().SetSuccessResult(&response).SetHeader(jfApikeyHeader, configFile.APIKey).Put(url)
+	if err != nil {
+		fmt.Println("\tError calling out to service", err)
+		return
+	}
+
+	if request.StatusCode != 200 {
+		fmt.Printf("\tAn error happened when attempting to %s function\n", action)
+		return
+	}
+
+	fmt.Printf("\tFunction %s (%s) %sd successfully\n", fd.Name, fd.FunctionId, action)
+}
+
+func deleteFunction(opts entities.FunctionDeleteCommand) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	fd, err := lookupLibraryItem(configFile.APIKey, opts.Args.Id)
+	if err != nil {
+		printLookupError(err)
+		return
+	}
+
+	if fd.Published && !opts.Force {
+		fmt.Printf("\tFunction %s (%s) is published, withdraw it first or use --force to delete it anyway\n", fd.Name, fd.FunctionId)
+		return
+	}
+
+	versions := fd.Versions
+	if opts.Version != 0 {
+		v := findVersion(fd, opts.Version)
+		if v == nil {
+			fmt.Printf("\tVersion %d not found, available versions: %s\n", opts.Version, availableVersions(fd))
+			return
+		}
+		versions = []entities.VersionDetailsResponse{*v}
+	}
+
+	greenBold := color.New(color.FgGreen, color.Underline).SprintFunc()
+	redBold := color.New(color.FgRed, color.Bold).SprintFunc()
+
+	if opts.Version != 0 {
+		fmt.Printf("%s version %d of %s (%s)\n", redBold("Deleting"), opts.Version, fd.Name, fd.FunctionId)
+	} else {
+		fmt.Printf("%s function %s (%s) and all %d version(s)\n", redBold("Deleting"), fd.Name, fd.FunctionId, len(fd.Versions))
+	}
+
+	for _, v := range versions {
+		fmt.Printf("  %s %d (latest: %t)\n", greenBold("Version:"), v.Version, v.Latest)
+		for _, size := range v.Sizes {
+			fmt.Printf("    %s %s\n", greenBold("Size:"), size.Size)
+			fmt.Printf("    %s %s\n", greenBold("URL:"), functionUrl(fd.FunctionId, size))
+		}
+	}
+	fmt.Println()
+
+	if !opts.Yes && !confirm("This cannot be undone, are you sure?") {
+		fmt.Println("\tCancelled")
+		return
+	}
+
+	url := p48CoreService + "/library/" + fd.FunctionId
+	request := req.NewClient().NewRequest().SetHeader(jfApikeyHeader, configFile.APIKey)
+	if opts.Version != 0 {
+		request.SetQueryParam("version", fmt.Sprintf("%d", opts.Version))
+	}
+
+	response, err := request.Delete(url)
+	if err != nil {
+		fmt.Println("\tError calling out to service", err)
+		return
+	}
+
+	if response.StatusCode != 200 && response.StatusCode != 204 {
+		fmt.Println("\tAn error happened when attempting to delete function")
+		return
+	}
+
+	clearLibraryCache(configFile.APIKey)
+
+	if opts.Version != 0 {
+		fmt.Printf("\tVersion %d of %s deleted successfully\n", opts.Version, fd.Name)
+	} else {
+		fmt.Printf("\tFunction %s deleted successfully\n", fd.Name)
+	}
+}
+
+func rollbackFunction(ref string, to int) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	fd, err := lookupLibraryItem(configFile.APIKey, ref)
+	if err != nil {
+		printLookupError(err)
+		return
+	}
+
+	target := findVersion(fd, to)
+	if target == nil {
+		fmt.Printf("\tVersion %d not found, available versions: %s\n", to, availableVersions(fd))
+		return
+	}
+
+	if target.Latest {
+		fmt.Printf("\tVersion %d is already the latest version of %s\n", to, fd.Name)
+		return
+	}
+
+	fmt.Printf("\tRolling back %s (%s) to version %d\n", fd.Name, fd.FunctionId, to)
+
+	var rollbackResponse entities.DeployedFunctionResponse
+	var errorDetails entities.ErrorDetails
+	url := p48CoreService + "/library/rollback/" + fd.FunctionId
+
+	request, err := req.NewClient().NewRequest().SetQueryParam("version", fmt.Sprintf("%d", to)).SetSuccessResult(&rollbackResponse).SetErrorResult(&errorDetails).SetHeader(jfApikeyHeader, configFile.APIKey).Put(url)
+	if err != nil {
+		fmt.Println("\tError calling out to service", err)
+		return
+	}
+
+	if request.StatusCode != 200 {
+		fmt.Println("\tAn error happened when attempting to roll back, this normally happens when an upgrade is already in progress")
+		fmt.Printf("\tSupport ID: %s \nError: %s\n", errorDetails.ErrorId, errorDetails.ErrorMessage)
+		return
+	}
+
+	var opIds []string
+	for _, v := range rollbackResponse.DeployedDetails {
+		opIds = append(opIds, v.Opid)
+	}
+
+	if len(opIds) > 0 {
+		fmt.Println("Waiting for rollback to be live..")
+		if !checkIfDeployedSuccessfully(fd.FunctionId, opIds, configFile.APIKey) {
+			return
+		}
+	}
+
+	clearLibraryCache(configFile.APIKey)
+
+	fd, err = fetchLibraryItem(configFile.APIKey, fd.FunctionId)
+	if err != nil {
+		fmt.Println("\tRollback requested, but the function could not be read back:", err)
+		return
+	}
+
+	latest := findVersionOrLatest(fd, 0)
+	if latest == nil {
+		fmt.Println("\tRollback requested, but no latest version is set")
+		return
+	}
+
+	//Without operations to wait on, the library is the only proof the rollback happened
+	if latest.Version != to {
+		fmt.Printf("\tRollback failed, %s is still running version %d instead of %d\n", fd.Name, latest.Version, to)
+		return
+	}
+
+	fmt.Printf("\tFunction %s is now running version %d\n", fd.Name, latest.Version)
+}
+
+// confirm asks a yes/no question on the terminal, anything but yes is a no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// checkIfDeployedSuccessfully polls the operations until they are all deployed, returning false
+// if the service errors or the operations are still not complete after maxOpsLoops checks
+func checkIfDeployedSuccessfully(functionId string, opsLink []string, key string) bool {
+
+	type opsLinkStatus struct {
+		Complete   bool
+		opsUrl     string
+		opsLink    string
+		functionId string
+	}
+
+	var opsLinkStatuses []opsLinkStatus
+
+	for _, v := range opsLink {
+		opsLinkStatuses = append(opsLinkStatuses, opsLinkStatus{Complete: false, opsLink: p48CoreService + "/upload/" + v + "/" + functionId})
+	}
+
+	client := req.NewClient()
+
+	for i := 1; i < maxOpsLoops+1; i++ {
+		for index, v := range opsLinkStatuses {
+
+			if v.Complete {
+				continue
+			}
+
+			ops := Operations{}
+
+			request, err := client.NewRequest().SetSuccessResult(&ops).SetHeader(jfApikeyHeader, key).Get(v.opsLink)
+			if err != nil || request.StatusCode != 200 {
+				fmt.Println("\tError calling backend service to validate status", err)
+				return false
+			}
+
+			if ops.Status == "DEPLOYED" {
+				opsLinkStatuses[index].Complete = true
+			} else {
+				fmt.Printf("Count %d/%d : Operation is not complete, waiting for function to be ready, status : %s\n", i, maxOpsLoops, ops.Status)
+			}
+
+		}
+
+		//Check if all operations are complete
+		allComplete := true
+		for _, v := range opsLinkStatuses {
+			if !v.Complete {
+				allComplete = false
+			}
+		}
+
+		if allComplete {
+			fmt.Println("\n\tOperation is complete, function(s) is ready to be used!")
+			return true
+		}
+
+		time.Sleep(30 * time.Second)
+	}
+
+	fmt.Println("\n\tOperation did not complete in time, check the status with 'jellyfaas library -d " + functionId + "'")
+	return false
+}
+
+func functionUrl(functionId string, size entities.VersionDetailsResponseSizes) string {
+	return jellyfaasEndpoint + size.FunctionId + "/" + functionId
+}
+
+// invokeTarget is everything needed to call one size of a deployed function
+type invokeTarget struct {
+	function *entities.LibraryItemDetailsResponse
+	version  *entities.VersionDetailsResponse
+	size     entities.VersionDetailsResponseSizes
+	url      string
+	method   string
+}
+
+func resolveInvokeTarget(apiKey string, ref string, sizeName string) (*invokeTarget, error) {
+	fd, err := lookupLibraryItem(apiKey, ref)
+	if err != nil {
+		var ambiguous *entities.AmbiguousFunctionError
+		if errors.As(err, &ambiguous) || errors.Is(err, errLibraryUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot find function %q, is the name correct?", ref)
+	}
+
+	v := findVersionOrLatest(fd, 0)
+	if v == nil {
+		return nil, fmt.Errorf("function %s has no latest version", fd.Name)
+	}
+
+	size, err := entities.SelectSize(v.Sizes, sizeName)
+	if err != nil {
+		return nil, fmt.Errorf("function %s: %v", fd.Name, err)
+	}
+
+	method := strings.ToUpper(v.Requirements.RequestType)
+	if method == "" {
+		method = "GET"
+	}
+
+	return &invokeTarget{
+		function: fd,
+		version:  v,
+		size:     *size,
+		url:      functionUrl(fd.FunctionId, *size),
+		method:   method,
+	}, nil
+}
+
+// invokePayload is what gets sent to a function, a JSON body or a file upload, and where to put the response.
+// A streamed call returns as soon as the headers arrive and leaves the body for the caller to read
+// with ToBytes or as a stream, askStream also asks the function for an event stream.
+type invokePayload struct {
+	body      []byte
+	file      string
+	query     map[string]string
+	out       string
+	stream    bool
+	askStream bool
+}
+
+// callFunction sends one request to a function and times it, everything that calls functions
+// goes through here so auth and query params work the same way
+func callFunction(client *req.Client, target *invokeTarget, token string, payload invokePayload) (*req.Response, time.Duration, error) {
+	request := client.NewRequest().SetHeader(jfTokenHeader, token).SetQueryParams(payload.query)
+	if payload.file != "" {
+		request.SetFile("file", payload.file)
+	} else if len(payload.body) > 0 {
+		request.SetHeader("Content-Type", "application/json").SetBodyBytes(payload.body)
+	}
+
+	//Stream the response straight to disk, binary data never goes near the terminal
+	if payload.out != "" {
+		request.SetOutputFile(payload.out).SetDownloadCallbackWithInterval(func(info req.DownloadInfo) {
+			printProgress(info.DownloadedSize, info.Response.ContentLength)
+		}, 100*time.Millisecond)
+	}
+
+	if payload.stream {
+		request.DisableAutoReadResponse()
+	}
+	if payload.askStream {
+		request.SetHeader("Accept", "text/event-stream, */*")
+	}
+
+	start := time.Now()
+	response, err := request.Send(target.method, target.url)
+	return response, time.Since(start), err
+}
+
+// printProgress draws a download progress bar on stderr, so it never ends up in redirected output
+func printProgress(downloaded int64, total int64) {
+	const width = 30
+
+	if total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r  Downloaded %s", formatBytes(downloaded))
+		return
+	}
+
+	done := int(float64(width) * float64(downloaded) / float64(total))
+	if done > width {
+		done = width
+	}
+	bar := strings.Repeat("=", done) + strings.Repeat(" ", width-done)
+	fmt.Fprintf(os.Stderr, "\r  [%s] %3d%% %s / %s", bar, downloaded*100/total, formatBytes(downloaded), formatBytes(total))
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func invokeFunction(opts entities.InvokeCommand) bool {
+	if opts.Batch != "" {
+		return invokeBatch(opts)
+	}
+
+	if opts.Stream && opts.CheckOutput {
+		fmt.Fprintln(os.Stderr, "\t--stream cannot be used with --check-output, the check needs the whole response")
+		return false
+	}
+	if opts.Out != "" && opts.CheckOutput {
+		fmt.Fprintln(os.Stderr, "\t--out cannot be used with --check-output, the check needs the response as JSON")
+		return false
+	}
+
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
+
+	body, err := entities.ReadRequestBody(opts.Data, os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading request body:", err)
+		return false
+	}
+
+	query, err := entities.ParseQueryArgs(opts.Query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading query parameters:", err)
+		return false
+	}
+
+	if opts.File != "" && len(body) > 0 {
+		fmt.Fprintln(os.Stderr, "\t--data and --file cannot be used together")
+		return false
+	}
+
+	target, err := resolveInvokeTarget(configFile.APIKey, opts.Args.Id, opts.Size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	if err := checkInvokeFiles(target, opts.File, opts.Out); err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	if opts.Example {
+		if opts.Data != "" || opts.File != "" {
+			fmt.Fprintln(os.Stderr, "\t--example cannot be used with --data or --file")
+			return false
+		}
+		if isFileType(target.version.Requirements.InputType) {
+			fmt.Fprintf(os.Stderr, "\tFunction %s takes a file, it has no example input to send\n", target.function.Name)
+			return false
+		}
+
+		exampleBody, exampleQuery, err := examplePayload(target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\t"+err.Error())
+			return false
+		}
+
+		//Query parameters given on the command line win over the examples
+		for name, value := range query {
+			exampleQuery[name] = value
+		}
+		body, query = exampleBody, exampleQuery
+	}
+
+	if opts.Interactive {
+		if opts.Data != "" || opts.Example {
+			fmt.Fprintln(os.Stderr, "\t--interactive cannot be used with --data or --example")
+			return false
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+		body, query, err = promptRequest(reader, target, query, opts.File != "")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\n\tError building request:", err)
+			return false
+		}
+		offerToSaveRequest(reader, opts.Args.Id, body, query)
+	}
+
+	if !opts.NoValidate {
+		problems, err := validateInvokeInput(target, body, query, opts.File != "")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError validating request:", err)
+			return false
+		}
+		if len(problems) > 0 {
+			printValidationErrors("Request does not match the function's input requirements:", problems)
+			fmt.Fprintln(os.Stderr, "\nUse --no-validate to send it anyway.")
+			return false
+		}
+	}
+
+	token, err := requestToken(configFile.APIKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tAn error happened when attempting to get token:", err)
+		return false
+	}
+
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+	redBold := color.New(color.FgRed, color.Bold).SprintFunc()
+
+	fmt.Fprintf(os.Stderr, "%s %s %s\n", greenBold("Invoking:"), target.method, target.url)
+
+	//Only an event stream going to the terminal is shown as it arrives, the output check and
+	//the example comparison need the whole body
+	stream := opts.Out == "" && !opts.CheckOutput && !opts.Example
+
+	//Download next to --out and only move it into place once the function has succeeded,
+	//so an error response never replaces a file that is already there
+	download := ""
+	if opts.Out != "" {
+		tmp, err := os.CreateTemp(filepath.Dir(opts.Out), "."+filepath.Base(opts.Out)+"-*")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError creating output file:", err)
+			return false
+		}
+		tmp.Close()
+		download = tmp.Name()
+		defer os.Remove(download)
+	}
+
+	start := time.Now()
+	response, latency, err := callFunction(req.NewClient(), target, token.Token, invokePayload{body: body, file: opts.File, query: query, out: download, stream: stream, askStream: opts.Stream})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError calling function", err)
+		return false
+	}
+
+	if opts.Out != "" {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if stream && response.StatusCode < 400 && entities.IsEventStream(response.GetContentType()) {
+		fmt.Fprintf(os.Stderr, "%s %s\n", greenBold("Status:"), greenBold(response.Status))
+		fmt.Fprintf(os.Stderr, "%s %d ms to first byte\n\n", greenBold("Latency:"), latency.Milliseconds())
+		if err := streamInvokeResponse(response); err != nil {
+			fmt.Fprintln(os.Stderr, "\n\tError reading response stream:", err)
+			return false
+		}
+		return true
+	}
+
+	var responseBody []byte
+	if opts.Out == "" {
+		//Read the whole body here, the latency then covers it even when auto read was off
+		responseBody, err = response.ToBytes()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError reading response:", err)
+			return false
+		}
+		latency = time.Since(start)
+	}
+
+	status := greenBold(response.Status)
+	if response.StatusCode >= 400 {
+		status = redBold(response.Status)
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", greenBold("Status:"), status)
+	fmt.Fprintf(os.Stderr, "%s %d ms\n\n", greenBold("Latency:"), latency.Milliseconds())
+
+	if opts.Out != "" {
+		//An error response is not the file that was asked for, show it instead of keeping it
+		if response.StatusCode >= 400 {
+			responseBody, _ = os.ReadFile(download)
+		} else {
+			//Temp files are private, the saved file gets the usual permissions
+			_ = os.Chmod(download, 0644)
+			if err := os.Rename(download, opts.Out); err != nil {
+				fmt.Fprintln(os.Stderr, "\tError saving response:", err)
+				return false
+			}
+			info, err := os.Stat(opts.Out)
+			if err == nil {
+				fmt.Fprintf(os.Stderr, "%s %s written to %s\n", greenBold("Saved:"), formatBytes(info.Size()), opts.Out)
+			}
+		}
+	}
+
+	if responseBody != nil {
+		if entities.IsTextContent(response.GetContentType(), responseBody) && opts.Example {
+			showExampleComparison(target, responseBody)
+		} else if entities.IsTextContent(response.GetContentType(), responseBody) {
+			fmt.Println(entities.PrettyBody(responseBody))
+		} else {
+			fmt.Fprintf(os.Stderr, "Response is binary (%s, %s), use --out <path> to save it\n", response.GetContentType(), formatBytes(int64(len(responseBody))))
+		}
+	}
+
+	if response.StatusCode >= 400 {
+		return false
+	}
+
+	if opts.CheckOutput {
+		if checkOutputContract(target, responseBody) == contractMismatch {
+			return false
+		}
+	}
+	return true
+}
+
+// showExampleComparison prints a response next to the example output the function publishes,
+// one above the other when the terminal is too narrow for columns
+func showExampleComparison(target *invokeTarget, body []byte) {
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+
+	expected := "(no example output published)"
+	//Unlike the input example, the output example is published as plain JSON
+	if example := target.version.Requirements.OutputJsonExample; example != nil && *example != "" {
+		expected = entities.PrettyBody([]byte(*example))
+	}
+	actual := entities.PrettyBody(body)
+
+	width := 0
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		width, _, _ = term.GetSize(int(os.Stdout.Fd()))
+	}
+	if width < 80 {
+		fmt.Println(greenBold("Response:"))
+		fmt.Println(actual)
+		fmt.Println()
+		fmt.Println(greenBold("Example output:"))
+		fmt.Println(expected)
+		return
+	}
+
+	fmt.Print(entities.SideBySide("Response", actual, "Example output", expected, width))
+}
+
+// tokenSource shares a token between workers and replaces it when the function rejects it,
+// so long batches outlive the token they started with
+type tokenSource struct {
+	apiKey string
+	mu     sync.Mutex
+	token  string
+}
+
+func (t *tokenSource) get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.token
+}
+
+// refresh gets a new token unless another worker already replaced the stale one
+func (t *tokenSource) refresh(stale string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != stale {
+		return t.token, nil
+	}
+	token, err := requestToken(t.apiKey)
+	if err != nil {
+		return "", err
+	}
+	t.token = token.Token
+	return t.token, nil
+}
+
+func invokeBatch(opts entities.InvokeCommand) bool {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
+
+	if opts.Data != "" || opts.File != "" || opts.Example || opts.Interactive || opts.Stream || opts.CheckOutput {
+		fmt.Fprintln(os.Stderr, "\t--batch cannot be used with --data, --file, --example, --interactive, --stream or --check-output")
+		return false
+	}
+	if opts.Concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "\t--concurrency must be at least 1")
+		return false
+	}
+	if opts.Rate < 0 {
+		fmt.Fprintln(os.Stderr, "\t--rate cannot be negative")
+		return false
+	}
+	if opts.Resume && opts.Out == "" {
+		fmt.Fprintln(os.Stderr, "\t--resume needs the results file from the previous run, given with --out")
+		return false
+	}
+
+	query, err := entities.ParseQueryArgs(opts.Query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading query parameters:", err)
+		return false
+	}
+
+	inputs, err := entities.ReadBatchInputs(opts.Batch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	target, err := resolveInvokeTarget(configFile.APIKey, opts.Args.Id, opts.Size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	if isFileType(target.version.Requirements.InputType) || isFileType(target.version.Requirements.OutputType) {
+		fmt.Fprintf(os.Stderr, "\tFunction %s takes or returns files, --batch only supports JSON functions\n", target.function.Name)
+		return false
+	}
+
+	//Results are written in input order, so a previous run left a prefix of them behind
+	out := os.Stdout
+	done := 0
+	if opts.Out != "" {
+		if opts.Resume {
+			done, err = entities.ResumeBatch(opts.Out)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "\t"+err.Error())
+				return false
+			}
+			if done > len(inputs) {
+				fmt.Fprintf(os.Stderr, "\t%s has %d results but %s only has %d inputs, is it the right file?\n", opts.Out, done, opts.Batch, len(inputs))
+				return false
+			}
+		} else if info, err := os.Stat(opts.Out); err == nil && info.Size() > 0 {
+			fmt.Fprintf(os.Stderr, "\t%s already exists, use --resume to carry on from it or remove it to start again\n", opts.Out)
+			return false
+		}
+
+		out, err = os.OpenFile(opts.Out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError opening results file:", err)
+			return false
+		}
+		defer out.Close()
+	}
+
+	remaining := inputs[done:]
+	if done > 0 {
+		fmt.Fprintf(os.Stderr, "Resuming, %d of %d inputs already done\n", done, len(inputs))
+	}
+	if len(remaining) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing left to send")
+		return true
+	}
+
+	token, err := requestToken(configFile.APIKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tAn error happened when attempting to get token:", err)
+		return false
+	}
+	tokens := &tokenSource{apiKey: configFile.APIKey, token: token.Token}
+
+	fmt.Fprintf(os.Stderr, "Invoking %s %s with %d inputs, %d at a time\n", target.method, target.url, len(remaining), opts.Concurrency)
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+
+		throttle, stop := newThrottle(opts.Rate)
+		defer stop()
+
+		for i := range remaining {
+			if throttle != nil {
+				<-throttle
+			}
+			jobs <- i
+		}
+	}()
+
+	type indexedResult struct {
+		index  int
+		result entities.BatchResult
+	}
+	results := make(chan indexedResult)
+
+	client := req.NewClient()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results <- indexedResult{index, invokeBatchInput(client, target, tokens, remaining[index], query, !opts.NoValidate)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	//Hold on to results that finish early until everything before them is written
+	pending := map[int]entities.BatchResult{}
+	next := 0
+	failed := 0
+	showProgress := term.IsTerminal(int(os.Stderr.Fd()))
+
+	for r := range results {
+		pending[r.index] = r.result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if result.Error != "" {
+				failed++
+			}
+			line, err := json.Marshal(result)
+			if err != nil {
+				line, _ = json.Marshal(entities.BatchResult{Line: result.Line, Error: err.Error()})
+			}
+			if _, err := out.Write(append(line, '\n')); err != nil {
+				fmt.Fprintln(os.Stderr, "\n\tError writing results:", err)
+				return false
+			}
+		}
+
+		if showProgress {
+			fmt.Fprintf(os.Stderr, "\r  %d/%d done, %d failed", done+next, len(inputs), failed)
+		}
+	}
+	if showProgress {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	fmt.Fprintf(os.Stderr, "Sent %d inputs, %d failed\n", len(remaining), failed)
+	if failed > 0 {
+		return false
+	}
+	return true
+}
+
+// invokeBatchInput sends one line of a batch, getting a new token once if the old one has expired
+func invokeBatchInput(client *req.Client, target *invokeTarget, tokens *tokenSource, input entities.BatchInput, query map[string]string, validate bool) entities.BatchResult {
+	if input.Err != nil {
+		return entities.BatchResult{Line: input.Line, Error: input.Err.Error()}
+	}
+
+	if validate {
+		problems, err := validateInvokeInput(target, input.Body, query, false)
+		if err != nil {
+			return entities.BatchResult{Line: input.Line, Error: err.Error()}
+		}
+		if len(problems) > 0 {
+			var messages []string
+			for _, p := range problems {
+				messages = append(messages, p.Error())
+			}
+			return entities.BatchResult{Line: input.Line, Error: "request does not match the input schema: " + strings.Join(messages, "; ")}
+		}
+	}
+
+	token := tokens.get()
+	response, latency, err := callFunction(client, target, token, invokePayload{body: input.Body, query: query})
+	if err == nil && response.StatusCode == 401 {
+		token, err = tokens.refresh(token)
+		if err == nil {
+			response, latency, err = callFunction(client, target, token, invokePayload{body: input.Body, query: query})
+		}
+	}
+	if err != nil {
+		return entities.BatchResult{Line: input.Line, LatencyMs: float64(latency) / float64(time.Millisecond), Error: err.Error()}
+	}
+
+	return entities.NewBatchResult(input.Line, response.StatusCode, latency, response.Bytes())
+}
+
+// promptRequest asks for each required query parameter and each top-level body field, offering
+// the published example as the default, and builds the request from the answers
+func promptRequest(reader *bufio.Reader, target *invokeTarget, query map[string]string, hasFile bool) ([]byte, map[string]string, error) {
+	requirements := target.version.Requirements
+	cyanBold := color.New(color.FgCyan, color.Bold).SprintFunc()
+
+	//Prompts go to stderr, stdout is kept for the response. An empty answer takes the example,
+	//and an optional field can be left out with "-" even when it has one.
+	ask := func(name string, description string, kind string, required bool, example string) (string, bool, error) {
+		label := name
+		if kind != "" {
+			label += " (" + kind + ")"
+		}
+		if !required {
+			label += " [optional, - to leave out]"
+		}
+		fmt.Fprintln(os.Stderr, cyanBold(label))
+		if description != "" {
+			fmt.Fprintln(os.Stderr, "  "+description)
+		}
+		if example != "" {
+			fmt.Fprintf(os.Stderr, "  [%s]: ", example)
+		} else {
+			fmt.Fprint(os.Stderr, "  : ")
+		}
+
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return "", false, fmt.Errorf("no more input")
+		}
+		answer = strings.TrimSpace(answer)
+		if !required && (answer == "-" || (answer == "" && example == "")) {
+			return "", true, nil
+		}
+		if answer == "" {
+			answer = example
+		}
+		return answer, false, nil
+	}
+
+	for _, p := range requirements.QueryParams {
+		if !p.Required {
+			continue
+		}
+		if _, ok := query[p.Name]; ok {
+			continue
+		}
+		for {
+			answer, _, err := ask(p.Name, p.Description, "query", true, p.ExampleData)
+			if err != nil {
+				return nil, nil, err
+			}
+			if answer != "" {
+				query[p.Name] = answer
+				break
+			}
+			fmt.Fprintln(os.Stderr, "  A value is required")
+		}
+	}
+
+	if hasFile || isFileType(requirements.InputType) {
+		return nil, query, nil
+	}
+
+	schema, err := entities.DecodeJsonSchema(requirements.InputJsonSchemaEncoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read the input schema: %v", err)
+	}
+	if schema == nil {
+		return nil, query, nil
+	}
+
+	var example []byte
+	if requirements.InputJsonExample != nil && *requirements.InputJsonExample != "" {
+		example, _ = base64.StdEncoding.DecodeString(*requirements.InputJsonExample)
+	}
+
+	body := map[string]interface{}{}
+	for _, field := range entities.SchemaFields(schema, example) {
+		for {
+			answer, skip, err := ask(field.Name, field.Description, field.Type, field.Required, field.ExampleText())
+			if err != nil {
+				return nil, nil, err
+			}
+			if skip {
+				break
+			}
+			if answer == "" {
+				fmt.Fprintln(os.Stderr, "  A value is required")
+				continue
+			}
+
+			value, err := entities.ParseFieldValue(field, answer)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "  "+err.Error())
+				continue
+			}
+			body[field.Name] = value
+			break
+		}
+	}
+
+	encoded, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return encoded, query, nil
+}
+
+// offerToSaveRequest saves an assembled body so it can be sent again with --data @file
+func offerToSaveRequest(reader *bufio.Reader, ref string, body []byte, query map[string]string) {
+	if len(body) == 0 && len(query) == 0 {
+		return
+	}
+
+	confirm := func(prompt string) bool {
+		fmt.Fprint(os.Stderr, prompt)
+		answer, _ := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+
+	if !confirm("\nSave this request to reuse it? [y/N]: ") {
+		fmt.Fprintln(os.Stderr)
+		return
+	}
+
+	command := "jellyfaas invoke " + ref
+	if len(body) > 0 {
+		fmt.Fprint(os.Stderr, "File name [request.json]: ")
+		path, _ := reader.ReadString('\n')
+		path = strings.TrimSpace(path)
+		if path == "" {
+			path = "request.json"
+		}
+		if _, err := os.Stat(path); err == nil && !confirm(path+" already exists, overwrite it? [y/N]: ") {
+			fmt.Fprintln(os.Stderr, "\tRequest not saved")
+			return
+		}
+		if err := os.WriteFile(path, append(body, '\n'), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "\tError saving request:", err)
+			return
+		}
+		command += " -d @" + path
+	}
+
+	var names []string
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command += " -q " + strconv.Quote(name+"="+query[name])
+	}
+
+	fmt.Fprintf(os.Stderr, "Send it again with: %s\n\n", command)
+}
+
+// streamInvokeResponse prints a function's event stream as it arrives, with the text pulled
+// out of each event
+func streamInvokeResponse(response *req.Response) error {
+	defer response.Body.Close()
+
+	err := entities.ReadEventStream(response.Body, func(data string) error {
+		text := entities.StreamChunkText(data)
+		if text == "" {
+			text = data + "\n"
+		}
+		fmt.Print(text)
+		return nil
+	})
+	fmt.Println()
+	return err
+}
+
+// contractResult is the outcome of checking a response against the output schema
+type contractResult int
+
+const (
+	contractMatch contractResult = iota
+	contractMismatch
+	contractMissing
+)
+
+// checkOutputContract validates a response against the output schema the function declares,
+// printing the result. A function without an output schema has no contract to check.
+func checkOutputContract(target *invokeTarget, body []byte) contractResult {
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellowBold := color.New(color.FgYellow, color.Bold).SprintFunc()
+
+	problems, err := validateInvokeOutput(target, body)
+	if errors.Is(err, errNoOutputSchema) {
+		fmt.Fprintln(os.Stderr, yellowBold("\nFunction declares no output schema, there is no contract to check"))
+		return contractMissing
+	}
+	if err != nil {
+		printValidationErrors("Output contract check failed:", []entities.ValidationError{{Path: "/", Message: err.Error()}})
+		return contractMismatch
+	}
+
+	if len(problems) > 0 {
+		printValidationErrors("Output does not match the function's output schema:", problems)
+		return contractMismatch
+	}
+
+	fmt.Fprintln(os.Stderr, greenBold("\nOutput matches the function's output schema"))
+	return contractMatch
+}
+
+// errNoOutputSchema means the function returns a file or publishes no output schema
+var errNoOutputSchema = errors.New("function declares no output schema")
+
+func validateInvokeOutput(target *invokeTarget, body []byte) ([]entities.ValidationError, error) {
+	requirements := target.version.Requirements
+
+	if isFileType(requirements.OutputType) {
+		return nil, errNoOutputSchema
+	}
+
+	schema, err := entities.DecodeJsonSchema(requirements.OutputJsonSchemaEncoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the output schema: %v", err)
+	}
+	if schema == nil {
+		return nil, errNoOutputSchema
+	}
+
+	if len(body) == 0 {
+		return []entities.ValidationError{{Path: "/", Message: "response body is empty"}}, nil
+	}
+	return entities.ValidateJson(schema, body)
+}
+
+// examplePayload builds a request from the example input and the example query parameters
+// published with the function
+func examplePayload(target *invokeTarget) ([]byte, map[string]string, error) {
+	requirements := target.version.Requirements
+
+	query := map[string]string{}
+	for _, p := range requirements.QueryParams {
+		if p.ExampleData != "" {
+			query[p.Name] = p.ExampleData
+		}
+	}
+
+	if requirements.InputJsonExample == nil || *requirements.InputJsonExample == "" {
+		return nil, query, nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(*requirements.InputJsonExample)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the input example: %v", err)
+	}
+	return body, query, nil
+}
+
+func testContract(ref string, size string) bool {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
+
+	target, err := resolveInvokeTarget(configFile.APIKey, ref, size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	if isFileType(target.version.Requirements.InputType) || isFileType(target.version.Requirements.OutputType) {
+		fmt.Fprintf(os.Stderr, "\tFunction %s takes or returns files, contract tests only support JSON functions\n", target.function.Name)
+		return false
+	}
+
+	body, query, err := examplePayload(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+	redBold := color.New(color.FgRed, color.Bold).SprintFunc()
+
+	//A required parameter without an example is a gap in what was published, not a function failure
+	if missing := entities.CheckQueryParams(target.version.Requirements.QueryParams, query); len(missing) > 0 {
+		fmt.Println(redBold("FAIL: cannot build example request, required query parameters have no example"))
+		for _, m := range missing {
+			fmt.Printf("  %s\n", m.Error())
+		}
+		return false
+	}
+
+	token, err := requestToken(configFile.APIKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tAn error happened when attempting to get token:", err)
+		return false
+	}
+
+	fmt.Printf("%s %s version %d (%s %s)\n", greenBold("Contract test:"), target.function.Name, target.version.Version, target.method, target.url)
+
+	response, latency, err := callFunction(req.NewClient(), target, token.Token, invokePayload{body: body, query: query})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError calling function", err)
+		return false
+	}
+
+	fmt.Printf("%s %s in %d ms\n", greenBold("Status:"), response.Status, latency.Milliseconds())
+
+	if response.StatusCode >= 400 {
+		fmt.Println(redBold("\nFAIL: function rejected its own example input"))
+		fmt.Println(entities.PrettyBody(response.Bytes()))
+		return false
+	}
+
+	switch checkOutputContract(target, response.Bytes()) {
+	case contractMismatch:
+		fmt.Println(redBold("\nFAIL"))
+		return false
+	case contractMissing:
+		fmt.Println(color.New(color.FgYellow, color.Bold).Sprint("SKIPPED: no output contract"))
+		return true
+	}
+
+	fmt.Println(greenBold("PASS"))
+	return true
+}
+
+// validateInvokeInput checks the body against the input JSON schema and that every required
+// query parameter is present, saving a round trip to the function for a 400
+func validateInvokeInput(target *invokeTarget, body []byte, query map[string]string, hasFile bool) ([]entities.ValidationError, error) {
+	requirements := target.version.Requirements
+	problems := entities.CheckQueryParams(requirements.QueryParams, query)
+
+	if hasFile || isFileType(requirements.InputType) {
+		return problems, nil
+	}
+
+	schema, err := entities.DecodeJsonSchema(requirements.InputJsonSchemaEncoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the input schema: %v", err)
+	}
+	if schema == nil {
+		return problems, nil
+	}
+
+	if len(body) == 0 {
+		if entities.SchemaRequiresBody(schema) {
+			problems = append(problems, entities.ValidationError{Path: "/", Message: "request body is required"})
+		}
+		return problems, nil
+	}
+
+	bodyProblems, err := entities.ValidateJson(schema, body)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, bodyProblems...), nil
+}
+
+func printValidationErrors(title string, problems []entities.ValidationError) {
+	redBold := color.New(color.FgRed, color.Bold).SprintFunc()
+
+	fmt.Fprintln(os.Stderr, redBold(title))
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "  %s\n", p.Error())
+	}
+}
+
+// checkInvokeFiles checks the file options against what the function declares it takes and returns
+func checkInvokeFiles(target *invokeTarget, file string, out string) error {
+	requirements := target.version.Requirements
+
+	inputSchema, err := entities.DecodeFileSchema(requirements.InputFileSchema, requirements.InputFileSchemaEncoded)
+	if err != nil {
+		return fmt.Errorf("error reading the input file schema: %v", err)
+	}
+
+	if file != "" {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("cannot read file %s: %v", file, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory, not a file", file)
+		}
+		if err := entities.CheckFileExtension(file, inputSchema); err != nil {
+			return err
+		}
+	} else if isFileType(requirements.InputType) && (inputSchema == nil || inputSchema.Required) {
+		return fmt.Errorf("function %s expects a file, use --file <path>", target.function.Name)
+	}
+
+	if out == "" && isFileType(requirements.OutputType) {
+		return fmt.Errorf("function %s returns a file, use --out <path> to save it", target.function.Name)
+	}
+
+	return nil
+}
+
+func isFileType(ioType *string) bool {
+	return ioType != nil && strings.EqualFold(*ioType, "FILE")
+}
+
+func runBench(opts entities.BenchCommand) bool {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
 
-		time.Sleep(30 * time.Second)
+	body, err := entities.ReadRequestBody(opts.Data, os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading request body:", err)
+		return false
+	}
+
+	query, err := entities.ParseQueryArgs(opts.Query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading query parameters:", err)
+		return false
+	}
+
+	if opts.Concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "\t--concurrency must be at least 1")
+		return false
+	}
+	if opts.Requests < 0 {
+		fmt.Fprintln(os.Stderr, "\t--requests cannot be negative")
+		return false
+	}
+	if opts.Duration < 0 {
+		fmt.Fprintln(os.Stderr, "\t--duration cannot be negative")
+		return false
+	}
+	if opts.Rate < 0 {
+		fmt.Fprintln(os.Stderr, "\t--rate cannot be negative")
+		return false
+	}
+
+	requests := opts.Requests
+	if requests == 0 && opts.Duration == 0 {
+		requests = defaultBenchRequests
+	}
+
+	target, err := resolveInvokeTarget(configFile.APIKey, opts.Args.Id, opts.Size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\t"+err.Error())
+		return false
+	}
+
+	token, err := requestToken(configFile.APIKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tAn error happened when attempting to get token:", err)
+		return false
+	}
+	//A long run can outlive the token, so it is refreshed when the function says it has expired
+	tokens := &tokenSource{apiKey: configFile.APIKey, token: token.Token}
+
+	jsonOutput := opts.Output == "json"
+	if !jsonOutput {
+		plan := fmt.Sprintf("%d requests", requests)
+		if opts.Duration > 0 {
+			plan = "for " + opts.Duration.String()
+		}
+		fmt.Printf("Benchmarking %s %s (%s, %s) with %d workers, %s\n\n", target.method, target.url, target.function.Name, target.size.Size, opts.Concurrency, plan)
+	}
+
+	//The producer hands out work, limited by count, duration and rate, the workers send it
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+
+		throttle, stop := newThrottle(opts.Rate)
+		defer stop()
+
+		var deadline time.Time
+		if opts.Duration > 0 {
+			deadline = time.Now().Add(opts.Duration)
+		}
+
+		for sent := 0; requests == 0 || sent < requests; sent++ {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return
+			}
+			if throttle != nil {
+				<-throttle
+			}
+			jobs <- struct{}{}
+		}
+	}()
+
+	client := req.NewClient()
+	//Grown as results come in, sizing it from --requests up front could ask for more memory than there is
+	var results []entities.BenchResult
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				token := tokens.get()
+				response, latency, err := callFunction(client, target, token, invokePayload{body: body, query: query})
+				if err == nil && response.StatusCode == 401 {
+					token, err = tokens.refresh(token)
+					if err == nil {
+						response, latency, err = callFunction(client, target, token, invokePayload{body: body, query: query})
+					}
+				}
+				result := entities.BenchResult{Latency: latency, Err: err}
+				if err == nil {
+					result.Status = response.StatusCode
+				}
+
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := entities.SummariseBench(results, time.Since(start))
+
+	if jsonOutput {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError formatting report:", err)
+			return false
+		}
+		fmt.Println(string(out))
+		return true
+	}
+
+	displayBenchReport(report)
+	return true
+}
+
+// newThrottle ticks at most rate times a second, a rate of 0 or less is unlimited and gives a
+// nil channel. Call stop when done with it.
+func newThrottle(rate float64) (<-chan time.Time, func()) {
+	if rate <= 0 {
+		return nil, func() {}
+	}
+
+	//A huge rate rounds the interval down to nothing, which NewTicker does not accept
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval < time.Nanosecond {
+		interval = time.Nanosecond
+	}
+
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+func displayBenchReport(report entities.BenchReport) {
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Requests", "Errors", "Throughput (req/s)", "Min", "Mean", "p50", "p90", "p99", "Max"})
+	t.AppendRow([]interface{}{report.Requests, report.Errors, fmt.Sprintf("%.1f", report.Throughput),
+		formatMs(report.MinMs), formatMs(report.MeanMs), formatMs(report.P50Ms), formatMs(report.P90Ms), formatMs(report.P99Ms), formatMs(report.MaxMs)})
+	t.SetStyle(table.StyleColoredYellowWhiteOnBlack)
+	t.Render()
+
+	fmt.Printf("\n%s\n", greenBold("Status codes:"))
+	var codes []string
+	for code := range report.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Printf("  %-6s %d\n", code, report.StatusCodes[code])
+	}
+
+	if len(report.Histogram) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s\n", greenBold("Latency histogram:"))
+	largest := 0
+	for _, b := range report.Histogram {
+		if b.Count > largest {
+			largest = b.Count
+		}
+	}
+	for _, b := range report.Histogram {
+		bar := 0
+		if largest > 0 {
+			bar = b.Count * benchHistogramWidth / largest
+		}
+		fmt.Printf("  %10s - %-10s | %-*s %d\n", formatMs(b.FromMs), formatMs(b.ToMs), benchHistogramWidth, strings.Repeat("■", bar), b.Count)
+	}
+}
+
+func formatMs(ms float64) string {
+	return fmt.Sprintf("%.1f ms", ms)
+}
+
+func queryFunction(opts entities.QueryCommand) bool {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return false
+	}
+
+	prompt := strings.TrimSpace(strings.Join(opts.Args.Prompt, " "))
+	if prompt == "" {
+		fmt.Fprintln(os.Stderr, "\tA prompt is required, for example: jellyfaas query --function weathercompare \"Compare London and Paris\"")
+		return false
+	}
+
+	token, err := requestToken(configFile.APIKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tAn error happened when attempting to get token:", err)
+		return false
+	}
+
+	query := entities.QueryRequest{Query: prompt, Function: opts.Function}
+
+	//The full service response is only there when the answer is not streamed
+	if opts.Output == "json" || opts.NoStream {
+		stop := startSpinner(fmt.Sprintf("Asking %s...", opts.Function))
+		body, err := sendQuery(token.Token, query)
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError querying function:", err)
+			return false
+		}
+		if opts.Output == "json" {
+			fmt.Println(entities.PrettyBody(body))
+			return true
+		}
+		return printQueryAnswer(body, opts.Raw)
+	}
+
+	stop := startSpinner(fmt.Sprintf("Asking %s...", opts.Function))
+	response, err := openQueryStream(token.Token, query)
+	if err != nil {
+		stop()
+		fmt.Fprintln(os.Stderr, "\tError querying function:", err)
+		return false
+	}
+
+	//Endpoints that do not stream send the whole answer in one go
+	if !entities.IsEventStream(response.GetContentType()) {
+		body, err := response.ToBytes()
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\tError reading the query service response:", err)
+			return false
+		}
+		return printQueryAnswer(body, opts.Raw)
+	}
+	defer response.Body.Close()
+
+	renderer := &markdownStream{raw: opts.Raw}
+	first := true
+	err = entities.ReadEventStream(response.Body, func(data string) error {
+		if first {
+			stop()
+			first = false
+		}
+		renderer.write(entities.StreamChunkText(data))
+		return nil
+	})
+	if first {
+		stop()
+	}
+	renderer.finish()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading the answer stream:", err)
+		return false
+	}
+	if !renderer.written {
+		fmt.Fprintln(os.Stderr, "\tThe query service returned no answer")
+		return false
+	}
+	return true
+}
+
+// printQueryAnswer prints the answer from a complete query service response
+func printQueryAnswer(body []byte, raw bool) bool {
+	var response entities.QueryResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		fmt.Fprintln(os.Stderr, "\tError reading the query service response:", err)
+		return false
+	}
+
+	if response.Answer == "" {
+		fmt.Fprintln(os.Stderr, "\tThe query service returned no answer")
+		return false
+	}
+
+	if raw {
+		fmt.Println(response.Answer)
+		return true
+	}
+
+	rendered, err := mdToANSI(response.Answer)
+	if err != nil {
+		fmt.Println(response.Answer)
+		return true
+	}
+	fmt.Println(rendered)
+	return true
+}
+
+// markdownStream renders markdown as it arrives. Raw text is printed straight away, otherwise
+// each block is rendered once it is complete, as glamour needs the whole block to lay it out.
+type markdownStream struct {
+	raw     bool
+	pending string
+	written bool
+}
+
+func (m *markdownStream) write(text string) {
+	if text == "" {
+		return
+	}
+	m.written = true
+
+	if m.raw {
+		fmt.Print(text)
+		return
+	}
+
+	complete, pending := entities.MarkdownBlocks(m.pending + text)
+	m.pending = pending
+	m.render(complete)
+}
+
+func (m *markdownStream) finish() {
+	if m.raw {
+		if m.written {
+			fmt.Println()
+		}
+		return
+	}
+	m.render(m.pending)
+	m.pending = ""
+}
+
+func (m *markdownStream) render(markdown string) {
+	if strings.TrimSpace(markdown) == "" {
+		return
+	}
+	rendered, err := mdToANSI(markdown)
+	if err != nil {
+		fmt.Print(markdown)
+		return
+	}
+	fmt.Print(rendered)
+}
+
+// sendQuery posts a query to the AI query service and returns the raw response body
+func sendQuery(token string, query entities.QueryRequest) ([]byte, error) {
+	url := p48QueryService + "/function"
+
+	response, err := req.NewClient().NewRequest().SetHeader(jfTokenHeader, token).SetBody(query).Post(url)
+	if err != nil {
+		return nil, fmt.Errorf("error calling out to the query service: %v", err)
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("query service returned %s: %s", response.Status, strings.TrimSpace(response.String()))
+	}
+
+	return response.Bytes(), nil
+}
+
+// openQueryStream asks the query service to stream its answer and returns once the headers
+// arrive, the caller reads the body. Services that cannot stream just send the full response.
+func openQueryStream(token string, query entities.QueryRequest) (*req.Response, error) {
+	url := p48QueryService + "/function"
+
+	response, err := req.NewClient().NewRequest().
+		SetHeader(jfTokenHeader, token).
+		SetHeader("Accept", "text/event-stream, application/json").
+		SetBody(query).
+		DisableAutoReadResponse().
+		Post(url)
+	if err != nil {
+		return nil, fmt.Errorf("error calling out to the query service: %v", err)
+	}
+
+	if response.StatusCode != 200 {
+		body, _ := response.ToString()
+		return nil, fmt.Errorf("query service returned %s: %s", response.Status, strings.TrimSpace(body))
+	}
+
+	return response, nil
+}
+
+// startSpinner shows a spinner on stderr while waiting, call the returned func to clear it.
+// Nothing is shown when stderr is not a terminal.
+func startSpinner(message string) func() {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for i := 0; ; i++ {
+			fmt.Fprintf(os.Stderr, "\r%s %s", color.CyanString(frames[i%len(frames)]), message)
+			select {
+			case <-done:
+				fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", len(message)+2))
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// chatHistory is the line editing history for chat, kept in a file in the config directory
+type chatHistory struct {
+	entries []string
+	path    string
+}
+
+func loadChatHistory() *chatHistory {
+	history := &chatHistory{}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return history
+	}
+	history.path = filepath.Join(configDir, "jellyfaas", chatHistoryFile)
+
+	buf, err := os.ReadFile(history.path)
+	if err != nil {
+		return history
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if line != "" {
+			history.entries = append(history.entries, line)
+		}
+	}
+	if len(history.entries) > maxChatHistory {
+		history.entries = history.entries[len(history.entries)-maxChatHistory:]
+	}
+	return history
+}
+
+func (h *chatHistory) Add(entry string) {
+	if entry == "" {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxChatHistory {
+		h.entries = h.entries[1:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString(entry + "\n")
+}
+
+func (h *chatHistory) Len() int {
+	return len(h.entries)
+}
+
+// At returns the entries newest first, as the terminal expects
+func (h *chatHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+// chatInput reads lines with editing and history when stdin is a terminal, or plain lines when it is piped
+type chatInput struct {
+	terminal *term.Terminal
+	reader   *bufio.Reader
+}
+
+func newChatInput(history *chatHistory) *chatInput {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return &chatInput{reader: bufio.NewReader(os.Stdin)}
+	}
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	terminal.History = history
+	return &chatInput{terminal: terminal}
+}
+
+func (c *chatInput) readLine(prompt string) (string, error) {
+	if c.terminal == nil {
+		fmt.Print(prompt)
+		line, err := c.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	//Only hold the terminal in raw mode while editing a line, so answers print normally
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		_ = c.terminal.SetSize(width, height)
+	}
+	c.terminal.SetPrompt(prompt)
+
+	line, err := c.terminal.ReadLine()
+	if err == term.ErrPasteIndicator {
+		err = nil
+	}
+	return strings.TrimSpace(line), err
+}
+
+func chat(opts entities.ChatCommand) {
+	configFile, err := readP48KeyFile()
+	if err != nil {
+		return
+	}
+
+	greenBold := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellowBold := color.New(color.FgYellow, color.Bold).SprintFunc()
+
+	function := opts.Function
+	raw := false
+	var messages []entities.QueryMessage
+	var sessionId string
+
+	fmt.Printf("Chatting with %s, type /help for commands or /exit to leave.\n\n", greenBold(function))
+
+	input := newChatInput(loadChatHistory())
+	for {
+		line, err := input.readLine(yellowBold("you> "))
+		if err != nil {
+			fmt.Println()
+			return
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			command, argument, _ := strings.Cut(line, " ")
+			argument = strings.TrimSpace(argument)
+
+			switch command {
+			case "/exit", "/quit":
+				return
+			case "/help":
+				fmt.Println("  /function <name>  switch to another AI function")
+				fmt.Println("  /save <file>      save the conversation as markdown")
+				fmt.Println("  /raw              toggle showing the raw JSON responses")
+				fmt.Println("  /clear            forget the conversation so far")
+				fmt.Println("  /exit             leave the chat")
+			case "/function":
+				if argument == "" {
+					fmt.Printf("  Current function: %s\n", function)
+					continue
+				}
+				function = argument
+				messages = nil
+				sessionId = ""
+				fmt.Printf("  Switched to %s, starting a new conversation\n", greenBold(function))
+			case "/save":
+				if argument == "" {
+					fmt.Println("  Usage: /save <file>")
+					continue
+				}
+				if err := os.WriteFile(argument, []byte(entities.ChatTranscriptMarkdown(messages, time.Now())), 0644); err != nil {
+					fmt.Println("  Error saving transcript:", err)
+					continue
+				}
+				fmt.Printf("  Conversation saved to %s\n", argument)
+			case "/raw":
+				raw = !raw
+				fmt.Printf("  Raw JSON responses: %t\n", raw)
+			case "/clear":
+				messages = nil
+				sessionId = ""
+				fmt.Println("  Conversation cleared")
+			default:
+				fmt.Printf("  Unknown command %s, type /help for commands\n", command)
+			}
+			continue
+		}
+
+		//Tokens are short lived, get a fresh one for every question
+		token, err := requestToken(configFile.APIKey)
+		if err != nil {
+			fmt.Println("  An error happened when attempting to get token:", err)
+			continue
+		}
+
+		query := entities.QueryRequest{Query: line, Function: function, History: messages, SessionId: sessionId}
+
+		stop := startSpinner(fmt.Sprintf("Asking %s...", function))
+		body, err := sendQuery(token.Token, query)
+		stop()
+		if err != nil {
+			fmt.Println("  Error querying function:", err)
+			continue
+		}
+
+		var response entities.QueryResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			fmt.Println("  Error reading the query service response:", err)
+			continue
+		}
+
+		if response.SessionId != "" {
+			sessionId = response.SessionId
+		}
+		messages = append(messages,
+			entities.QueryMessage{Role: "user", Content: line},
+			entities.QueryMessage{Role: "assistant", Content: response.Answer, Function: function})
+
+		if raw {
+			fmt.Println(entities.PrettyBody(body))
+			continue
+		}
+
+		rendered, err := mdToANSI(response.Answer)
+		if err != nil {
+			rendered = response.Answer
+		}
+		fmt.Println(rendered)
 	}
 }
 
@@ -743,16 +3480,6 @@ func writeP48KeyFile(config *Config) error {
 	return ioutil.WriteFile(*filename, buf, 0644)
 }
 
-func getMetricsBySize(size string, response entities.LibraryItemResponse) (int, time.Duration) {
-
-	for _, v := range *response.Sizes {
-		if v.Size == size {
-			return v.InvocationCount, v.AvgResponseTimeMs
-		}
-	}
-	return 0, time.Duration(0)
-}
-
 func createProject(functionName, language, destinationDir string, always bool) {
 	// Check if the directory exists
 	_, err := readP48KeyFile()
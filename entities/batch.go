@@ -0,0 +1,106 @@
+package entities
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type BatchInput struct {
+	Line int
+	Body []byte
+	Err  error
+}
+
+type BatchResult struct {
+	Line      int         `json:"line"`
+	Status    int         `json:"status,omitempty"`
+	LatencyMs float64     `json:"latencyMs"`
+	Response  interface{} `json:"response,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// ReadBatchInputs reads a JSONL file of request bodies, one per line. Blank lines are skipped,
+// lines that are not JSON are kept with an error so they still get a result.
+func ReadBatchInputs(path string) ([]BatchInput, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch file: %v", err)
+	}
+	defer f.Close()
+
+	var inputs []BatchInput
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		body := bytes.TrimSpace(scanner.Bytes())
+		if len(body) == 0 {
+			continue
+		}
+
+		input := BatchInput{Line: line, Body: append([]byte{}, body...)}
+		if !json.Valid(body) {
+			input.Err = fmt.Errorf("line is not valid JSON")
+		}
+		inputs = append(inputs, input)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %v", err)
+	}
+
+	return inputs, nil
+}
+
+// NewBatchResult builds the result line for a response, keeping a JSON response as JSON and
+// anything else as a string.
+func NewBatchResult(line int, status int, latency time.Duration, body []byte) BatchResult {
+	result := BatchResult{Line: line, Status: status, LatencyMs: toMs(latency)}
+
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(trimmed) == 0:
+	case json.Valid(trimmed):
+		result.Response = json.RawMessage(trimmed)
+	default:
+		result.Response = string(body)
+	}
+
+	if status >= 400 {
+		result.Error = fmt.Sprintf("function returned status %d", status)
+	}
+	return result
+}
+
+// ResumeBatch counts the results already written by an earlier run. A line cut off part way
+// through, because the run was stopped while writing it, is removed so it gets sent again.
+func ResumeBatch(path string) (int, error) {
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read previous results: %v", err)
+	}
+
+	done := 0
+	end := 0
+	for end < len(buf) {
+		next := bytes.IndexByte(buf[end:], '\n')
+		if next < 0 || !json.Valid(buf[end:end+next]) {
+			break
+		}
+		end += next + 1
+		done++
+	}
+
+	if end < len(buf) {
+		if err := os.Truncate(path, int64(end)); err != nil {
+			return 0, fmt.Errorf("failed to remove the incomplete result: %v", err)
+		}
+	}
+	return done, nil
+}
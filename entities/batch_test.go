@@ -0,0 +1,149 @@
+package entities
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResumeBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		missing  bool
+		wantDone int
+		wantLeft string
+	}{
+		{
+			name:     "missing file",
+			missing:  true,
+			wantDone: 0,
+		},
+		{
+			name:     "empty file",
+			contents: "",
+			wantDone: 0,
+		},
+		{
+			name:     "clean file",
+			contents: "{\"line\":1}\n{\"line\":2}\n",
+			wantDone: 2,
+			wantLeft: "{\"line\":1}\n{\"line\":2}\n",
+		},
+		{
+			name:     "cut off mid-line",
+			contents: "{\"line\":1}\n{\"line\":2}\n{\"line\":3,\"resp",
+			wantDone: 2,
+			wantLeft: "{\"line\":1}\n{\"line\":2}\n",
+		},
+		{
+			name:     "complete JSON without its newline",
+			contents: "{\"line\":1}\n{\"line\":2}",
+			wantDone: 1,
+			wantLeft: "{\"line\":1}\n",
+		},
+		{
+			name:     "bad line stops the count",
+			contents: "{\"line\":1}\nnot json\n{\"line\":3}\n",
+			wantDone: 1,
+			wantLeft: "{\"line\":1}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.jsonl")
+			if !tt.missing {
+				if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			done, err := ResumeBatch(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if done != tt.wantDone {
+				t.Errorf("got %d done, want %d", done, tt.wantDone)
+			}
+
+			left, err := os.ReadFile(path)
+			if tt.missing {
+				if !os.IsNotExist(err) {
+					t.Errorf("a missing results file should not be created, got err %v", err)
+				}
+				return
+			}
+			if string(left) != tt.wantLeft {
+				t.Errorf("file left as %q, want %q", left, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestNewBatchResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantResp  string
+		wantError string
+	}{
+		{name: "json response kept as json", status: 200, body: " {\"a\":1}\n", wantResp: `{"a":1}`},
+		{name: "text response kept as a string", status: 200, body: "hello", wantResp: `"hello"`},
+		{name: "empty response left out", status: 204, body: "  "},
+		{name: "error status", status: 500, body: "boom", wantResp: `"boom"`, wantError: "function returned status 500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewBatchResult(7, tt.status, 1500*time.Microsecond, []byte(tt.body))
+
+			if result.Line != 7 || result.Status != tt.status || result.LatencyMs != 1.5 {
+				t.Errorf("got line %d status %d latency %v", result.Line, result.Status, result.LatencyMs)
+			}
+			if result.Error != tt.wantError {
+				t.Errorf("got error %q, want %q", result.Error, tt.wantError)
+			}
+
+			got := ""
+			if result.Response != nil {
+				buf, err := json.Marshal(result.Response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(buf)
+			}
+			if got != tt.wantResp {
+				t.Errorf("got response %s, want %s", got, tt.wantResp)
+			}
+		})
+	}
+}
+
+func TestReadBatchInputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	if err := os.WriteFile(path, []byte("{\"a\":1}\n\n  \nnot json\n[2]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs, err := ReadBatchInputs(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantLines := []int{1, 4, 5}
+	if len(inputs) != len(wantLines) {
+		t.Fatalf("got %d inputs, want %d", len(inputs), len(wantLines))
+	}
+	for i, input := range inputs {
+		if input.Line != wantLines[i] {
+			t.Errorf("input %d is line %d, want %d", i, input.Line, wantLines[i])
+		}
+		if (input.Err != nil) != (input.Line == 4) {
+			t.Errorf("line %d: got err %v", input.Line, input.Err)
+		}
+	}
+}
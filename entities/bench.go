@@ -0,0 +1,122 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const benchHistogramBuckets = 10
+
+type BenchResult struct {
+	Latency time.Duration
+	Status  int
+	Err     error
+}
+
+type BenchReport struct {
+	Requests    int               `json:"requests"`
+	Errors      int               `json:"errors"`
+	DurationMs  float64           `json:"durationMs"`
+	Throughput  float64           `json:"throughputPerSecond"`
+	MinMs       float64           `json:"minMs"`
+	MeanMs      float64           `json:"meanMs"`
+	P50Ms       float64           `json:"p50Ms"`
+	P90Ms       float64           `json:"p90Ms"`
+	P99Ms       float64           `json:"p99Ms"`
+	MaxMs       float64           `json:"maxMs"`
+	StatusCodes map[string]int    `json:"statusCodes"`
+	Histogram   []HistogramBucket `json:"histogram"`
+}
+
+type HistogramBucket struct {
+	FromMs float64 `json:"fromMs"`
+	ToMs   float64 `json:"toMs"`
+	Count  int     `json:"count"`
+}
+
+// SummariseBench works out the latency percentiles, histogram, throughput and status code
+// breakdown for a benchmark run. Requests that failed before getting a response are counted
+// as errors and left out of the latency figures.
+func SummariseBench(results []BenchResult, elapsed time.Duration) BenchReport {
+	report := BenchReport{
+		Requests:    len(results),
+		DurationMs:  toMs(elapsed),
+		StatusCodes: map[string]int{},
+	}
+
+	var latencies []time.Duration
+	var total time.Duration
+	for _, r := range results {
+		if r.Err != nil {
+			report.Errors++
+			report.StatusCodes["error"]++
+			continue
+		}
+		if r.Status >= 400 {
+			report.Errors++
+		}
+		report.StatusCodes[fmt.Sprintf("%d", r.Status)]++
+		latencies = append(latencies, r.Latency)
+		total += r.Latency
+	}
+
+	if elapsed > 0 {
+		report.Throughput = float64(len(results)) / elapsed.Seconds()
+	}
+
+	if len(latencies) == 0 {
+		return report
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	report.MinMs = toMs(latencies[0])
+	report.MaxMs = toMs(latencies[len(latencies)-1])
+	report.MeanMs = toMs(total / time.Duration(len(latencies)))
+	report.P50Ms = toMs(percentile(latencies, 50))
+	report.P90Ms = toMs(percentile(latencies, 90))
+	report.P99Ms = toMs(percentile(latencies, 99))
+	report.Histogram = histogram(latencies)
+
+	return report
+}
+
+// percentile uses the nearest rank method on sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func histogram(sorted []time.Duration) []HistogramBucket {
+	min := sorted[0]
+	max := sorted[len(sorted)-1]
+
+	width := (max - min) / benchHistogramBuckets
+	if width <= 0 {
+		return []HistogramBucket{{FromMs: toMs(min), ToMs: toMs(max), Count: len(sorted)}}
+	}
+
+	buckets := make([]HistogramBucket, benchHistogramBuckets)
+	for i := range buckets {
+		buckets[i].FromMs = toMs(min + time.Duration(i)*width)
+		buckets[i].ToMs = toMs(min + time.Duration(i+1)*width)
+	}
+	buckets[benchHistogramBuckets-1].ToMs = toMs(max)
+
+	for _, l := range sorted {
+		i := int((l - min) / width)
+		if i >= benchHistogramBuckets {
+			i = benchHistogramBuckets - 1
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,115 @@
+package entities
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ChangeLogEntry struct {
+	Version int      `json:"version"`
+	Latest  bool     `json:"latest"`
+	Date    string   `json:"date,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	File    string   `json:"file,omitempty"`
+}
+
+// BuildChangeLog decodes the changelog of every version, newest first. The structured details are
+// used when present, the encoded changelog file is kept as a fallback.
+func BuildChangeLog(versions []VersionDetailsResponse) ([]ChangeLogEntry, error) {
+	var entries []ChangeLogEntry
+
+	for _, v := range versions {
+		entry := ChangeLogEntry{
+			Version: v.Version,
+			Latest:  v.Latest,
+			Date:    v.ChangeLogDetails.Date,
+			Added:   changeLogItems(v.ChangeLogDetails.Added),
+			Updated: changeLogItems(v.ChangeLogDetails.Updated),
+			Removed: changeLogItems(v.ChangeLogDetails.Removed),
+		}
+
+		if entry.Date == "" && !v.ReleaseDate.IsZero() {
+			entry.Date = v.ReleaseDate.Format("2006-01-02")
+		}
+
+		if v.ChangeLogFileEncoded != "" {
+			decoded, err := base64.StdEncoding.DecodeString(v.ChangeLogFileEncoded)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode changelog for version %d: %v", v.Version, err)
+			}
+			entry.File = strings.TrimSpace(string(decoded))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Version > entries[j].Version
+	})
+
+	return entries, nil
+}
+
+// ChangeLogMarkdown renders the changelog entries as markdown, ready for glamour.
+func ChangeLogMarkdown(name string, entries []ChangeLogEntry) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# %s changelog\n\n", name))
+
+	for _, e := range entries {
+		heading := fmt.Sprintf("## Version %d", e.Version)
+		if e.Date != "" {
+			heading += " - " + e.Date
+		}
+		if e.Latest {
+			heading += " (latest)"
+		}
+		sb.WriteString(heading + "\n\n")
+
+		hasDetails := len(e.Added) > 0 || len(e.Updated) > 0 || len(e.Removed) > 0
+		writeChangeLogSection(&sb, "Added", e.Added)
+		writeChangeLogSection(&sb, "Updated", e.Updated)
+		writeChangeLogSection(&sb, "Removed", e.Removed)
+
+		if !hasDetails {
+			if e.File != "" {
+				sb.WriteString(e.File + "\n\n")
+			} else {
+				sb.WriteString("_No changelog recorded for this version._\n\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+func writeChangeLogSection(sb *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+
+	sb.WriteString("### " + title + "\n\n")
+	for _, item := range items {
+		sb.WriteString("- " + item + "\n")
+	}
+	sb.WriteString("\n")
+}
+
+// changeLogItems splits a changelog section into one item per line, dropping any list markers.
+func changeLogItems(section []byte) []string {
+	var items []string
+	for _, line := range strings.Split(string(section), "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimPrefix(line, "- ")
+		line = strings.TrimPrefix(line, "* ")
+		line = strings.TrimSpace(line)
+		if line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
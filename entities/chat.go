@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type QueryMessage struct {
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	Function string `json:"-"`
+}
+
+// ChatTranscriptMarkdown renders a chat session as a markdown document.
+func ChatTranscriptMarkdown(messages []QueryMessage, savedAt time.Time) string {
+	var sb strings.Builder
+
+	sb.WriteString("# JellyFaaS chat transcript\n\n")
+	sb.WriteString(fmt.Sprintf("_Saved %s_\n\n", savedAt.Format(time.RFC1123)))
+
+	for _, m := range messages {
+		if m.Role == "user" {
+			sb.WriteString("## You\n\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("## %s\n\n", m.Function))
+		}
+		sb.WriteString(strings.TrimSpace(m.Content) + "\n\n")
+	}
+
+	return sb.String()
+}
@@ -5,9 +5,15 @@ import "time"
 type Options struct {
 	User      UserCommands       `command:"user" description:"User related commands"`
 	Secret    Secret             `command:"secret" description:"secret command"`
-	Library   ListLibraryCommand `command:"library" description:"List library"`
+	Library   ListLibraryCommand `command:"library" description:"List library" subcommands-optional:"true"`
 	Deploy    DeployCommands     `command:"deploy" description:"Deploy related commands"`
 	Publish   PublishCommands    `command:"publish" description:"Publish related commands"`
+	Function  FunctionCommands   `command:"function" description:"Manage deployed functions"`
+	Invoke    InvokeCommand      `command:"invoke" description:"Call a deployed function"`
+	Contract  ContractCommands   `command:"contract" description:"Check functions keep to their declared contract"`
+	Bench     BenchCommand       `command:"bench" description:"Load test a deployed function"`
+	Query     QueryCommand       `command:"query" description:"Ask an AI function a question through the query service"`
+	Chat      ChatCommand        `command:"chat" description:"Chat with an AI function through the query service"`
 	Token     GetTokenCommand    `command:"token" description:"Setup a token in the .jellyfaas file"`
 	Spec      CreateSpecCommand  `command:"spec" description:"Spec related commands"`
 	BadBuilds BadBuildsCommand   `command:"builds" description:"Bad builds related commands"`
@@ -51,7 +57,7 @@ type CreateCommand struct {
 type ListBadBuildsCommand struct{}
 
 type CleanBadBuildsCommand struct {
-	BuildId string `short:"b" long:"buildId" description:"Build ID" required:"true"`
+	BuildId string `short:"b" long:"buildId" description:"Build ID, a unique prefix of one, or the function name or ID of the bad build" required:"true"`
 }
 
 type CreateSpecCommand struct {
@@ -69,8 +75,77 @@ type UserCommands struct {
 type Secret struct{}
 
 type ListLibraryCommand struct {
-	Details string `short:"d" long:"details" description:"Details of the library" required:"false"`
-	ReadMe  bool   `short:"r" long:"readme" description:"View the Readme of the library" required:"false"`
+	Details   string                  `short:"d" long:"details" description:"Details of a library item, by ID, name, short name or a unique prefix of one" required:"false"`
+	ReadMe    bool                    `short:"r" long:"readme" description:"View the Readme of the library" required:"false"`
+	Version   int                     `long:"version" description:"Show the full details of a specific version (with --details)" required:"false"`
+	Search    LibrarySearchCommand    `command:"search" description:"Search the library by name, description, owner and tags"`
+	Tags      LibraryTagsCommand      `command:"tags" description:"List the library tags with item counts"`
+	Diff      LibraryDiffCommand      `command:"diff" description:"Compare the contract of two versions of a function"`
+	ChangeLog LibraryChangeLogCommand `command:"changelog" description:"Show the changelog of a function, newest version first"`
+	Stats     LibraryStatsCommand     `command:"stats" description:"Show invocation counts and average latency per function and size"`
+	Samples   LibrarySamplesCommand   `command:"samples" description:"Show the SDK code sample for a function"`
+	Url       LibraryUrlCommand       `command:"url" description:"Print the endpoint URL of a function size"`
+	Snippet   LibrarySnippetCommand   `command:"snippet" description:"Print a ready to run snippet that calls a function"`
+}
+
+type LibrarySearchCommand struct {
+	Tags      []string `short:"t" long:"tag" description:"Only show items with this tag (can be repeated)" required:"false"`
+	Owner     string   `short:"o" long:"owner" description:"Only show items from this owner" required:"false"`
+	Ai        bool     `short:"a" long:"ai" description:"Only show AI functions" required:"false"`
+	Published bool     `short:"p" long:"published" description:"Only show published functions" required:"false"`
+	Args      struct {
+		Terms []string `positional-arg-name:"terms" description:"Search terms"`
+	} `positional-args:"yes"`
+}
+
+type LibraryTagsCommand struct{}
+
+type LibraryStatsCommand struct {
+	SortBy string `short:"s" long:"sort-by" description:"Sort the rows by" choice:"invocations" choice:"latency" choice:"name" default:"invocations"`
+	Top    int    `short:"t" long:"top" description:"Only show the top N rows, 0 shows all" default:"0"`
+	Args   struct {
+		Id string `positional-arg-name:"id" description:"Only show stats for this function (ID, name or short name)"`
+	} `positional-args:"yes"`
+}
+
+type LibrarySamplesCommand struct {
+	Language string `short:"l" long:"language" description:"Language of the sample (python, go, js, ...), lists the available languages if not set" required:"false"`
+	Version  int    `long:"version" description:"Version to show the sample for, defaults to the latest" required:"false"`
+	Write    string `short:"w" long:"write" description:"Write the sample code to this file" required:"false"`
+	Args     struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type LibrarySnippetCommand struct {
+	As        string `short:"a" long:"as" description:"Snippet format" choice:"curl" choice:"httpie" choice:"python-requests" choice:"js-fetch" choice:"go" default:"curl"`
+	WithToken bool   `long:"with-token" description:"Fill in a real token instead of a placeholder" required:"false"`
+	Size      string `short:"s" long:"size" description:"Size to call, defaults to the smallest" required:"false"`
+	Args      struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type LibraryUrlCommand struct {
+	Size string `short:"s" long:"size" description:"Size to print the URL for, defaults to the smallest" required:"false"`
+	Args struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type LibraryChangeLogCommand struct {
+	Output string `short:"o" long:"output" description:"Output format" choice:"markdown" choice:"json" default:"markdown"`
+	Args   struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type LibraryDiffCommand struct {
+	From int `short:"f" long:"from" description:"Version to compare from" required:"true"`
+	To   int `short:"t" long:"to" description:"Version to compare to" required:"true"`
+	Args struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
 }
 
 type DeployCommands struct {
@@ -79,8 +154,101 @@ type DeployCommands struct {
 }
 
 type PublishCommands struct {
-	ID    string `short:"i" command:"id" description:"ID of the library item" required:"true"`
-	State bool   `short:"s" command:"state" description:"State of the library item" required:"true"`
+	IDs      []string `short:"i" long:"id" description:"ID, name or short name of the library item (can be repeated)" required:"false"`
+	Tags     []string `short:"t" long:"tag" description:"Select every library item with this tag (can be repeated)" required:"false"`
+	Withdraw bool     `short:"w" long:"withdraw" description:"Withdraw the library items instead of publishing them" required:"false"`
+	Args     struct {
+		IDs []string `positional-arg-name:"ids" description:"IDs, names or short names of the library items"`
+	} `positional-args:"yes"`
+}
+
+type InvokeCommand struct {
+	Data        string   `short:"d" long:"data" description:"JSON body, @file to read it from a file, or - to read it from stdin" required:"false"`
+	Query       []string `short:"q" long:"query" description:"Query parameter as key=value (can be repeated)" required:"false"`
+	File        string   `short:"f" long:"file" description:"File to upload, for functions with a FILE input" required:"false"`
+	Out         string   `short:"o" long:"out" description:"Save the response to this file, required for functions with a FILE output" required:"false"`
+	NoValidate  bool     `long:"no-validate" description:"Do not check the request against the function's input schema before sending" required:"false"`
+	CheckOutput bool     `long:"check-output" description:"Check the response against the function's output schema, exits non-zero on a mismatch" required:"false"`
+	Stream      bool     `long:"stream" description:"Ask the function for an event stream and print it as it arrives" required:"false"`
+	Example     bool     `long:"example" description:"Send the function's published example input and show the response next to its example output" required:"false"`
+	Batch       string   `long:"batch" description:"JSONL file with one request body per line, writes one JSONL result per line to --out or stdout" required:"false"`
+	Concurrency int      `short:"c" long:"concurrency" description:"Number of batch requests in flight at once" default:"4"`
+	Rate        float64  `short:"r" long:"rate" description:"Maximum batch requests per second, 0 is unlimited" required:"false"`
+	Resume      bool     `long:"resume" description:"Carry on a batch from the results already in --out" required:"false"`
+	Interactive bool     `short:"i" long:"interactive" description:"Prompt for each query parameter and body field, then send the request" required:"false"`
+	Size        string   `short:"s" long:"size" description:"Size to call, defaults to the smallest" required:"false"`
+	Args        struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type BenchCommand struct {
+	Concurrency int           `short:"c" long:"concurrency" description:"Number of requests in flight at once" default:"10"`
+	Requests    int           `short:"n" long:"requests" description:"Total number of requests to send, defaults to 100 unless --duration is set" required:"false"`
+	Duration    time.Duration `long:"duration" description:"Keep sending requests for this long, for example 30s or 2m" required:"false"`
+	Rate        float64       `short:"r" long:"rate" description:"Maximum requests per second across all workers, 0 is unlimited" required:"false"`
+	Data        string        `short:"d" long:"data" description:"JSON body, @file to read it from a file, or - to read it from stdin" required:"false"`
+	Query       []string      `short:"q" long:"query" description:"Query parameter as key=value (can be repeated)" required:"false"`
+	Size        string        `short:"s" long:"size" description:"Size to call, defaults to the smallest" required:"false"`
+	Output      string        `short:"o" long:"output" description:"Output format" choice:"text" choice:"json" default:"text"`
+	Args        struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ChatCommand struct {
+	Function string `short:"f" long:"function" description:"Name of the AI function to chat with" required:"true"`
+}
+
+type QueryCommand struct {
+	Function string `short:"f" long:"function" description:"Name of the AI function to query" required:"true"`
+	Raw      bool   `short:"r" long:"raw" description:"Print the answer as plain markdown, without rendering" required:"false"`
+	Output   string `short:"o" long:"output" description:"Output format, json prints the full service response" choice:"markdown" choice:"json" default:"markdown"`
+	NoStream bool   `long:"no-stream" description:"Wait for the whole answer instead of showing it as it arrives" required:"false"`
+	Args     struct {
+		Prompt []string `positional-arg-name:"prompt" description:"The question to ask"`
+	} `positional-args:"yes"`
+}
+
+type ContractCommands struct {
+	Test ContractTestCommand `command:"test" description:"Invoke a function with its example input and check the output against its schema"`
+}
+
+type ContractTestCommand struct {
+	Size string `short:"s" long:"size" description:"Size to call, defaults to the smallest" required:"false"`
+	Args struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type FunctionCommands struct {
+	Activate   FunctionStateCommand    `command:"activate" description:"Activate a function"`
+	Deactivate FunctionStateCommand    `command:"deactivate" description:"Deactivate a function, it stays in the library but cannot be called"`
+	Delete     FunctionDeleteCommand   `command:"delete" description:"Delete a function or a specific version of it"`
+	Rollback   FunctionRollbackCommand `command:"rollback" description:"Make an earlier version the latest version again"`
+}
+
+type FunctionRollbackCommand struct {
+	To   int `short:"t" long:"to" description:"Version to roll back to" required:"true"`
+	Args struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type FunctionDeleteCommand struct {
+	Version int  `long:"version" description:"Only delete this version" required:"false"`
+	Yes     bool `short:"y" long:"yes" description:"Do not ask for confirmation" required:"false"`
+	Force   bool `short:"f" long:"force" description:"Delete even if the function is published" required:"false"`
+	Args    struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type FunctionStateCommand struct {
+	Yes  bool `short:"y" long:"yes" description:"Do not ask for confirmation" required:"false"`
+	Args struct {
+		Id string `positional-arg-name:"id" description:"Function ID, name, short name or a unique prefix of one"`
+	} `positional-args:"yes" required:"yes"`
 }
 
 type CreateUserCommand struct {
@@ -92,7 +260,14 @@ type DeleteUserCommand struct {
 	Email string `short:"e" long:"email" description:"Email of the user" required:"true"`
 }
 
-type ListUsersCommand struct{}
+type ListUsersCommand struct {
+	Filter        string `short:"f" long:"filter" description:"Only show users whose name or email contains this text" required:"false"`
+	CreatedAfter  string `long:"created-after" description:"Only show users created after this date (YYYY-MM-DD or RFC3339)" required:"false"`
+	CreatedBefore string `long:"created-before" description:"Only show users created before this date (YYYY-MM-DD or RFC3339)" required:"false"`
+	SortBy        string `short:"s" long:"sort-by" description:"Sort users by field" choice:"name" choice:"email" choice:"created" choice:"updated" required:"false"`
+	Page          int    `short:"p" long:"page" description:"Page number to show, starting at 1" default:"1" required:"false"`
+	Limit         int    `short:"l" long:"limit" description:"Number of users per page, 0 shows all" default:"0" required:"false"`
+}
 
 type GetTokenCommand struct{}
 
@@ -119,9 +294,24 @@ type UserDetails struct {
 
 type Entity struct {
 	Entities []UserDetails `json:"entities" omitempty:"true"`
+	Total    *int          `json:"total,omitempty"`
+	Page     *int          `json:"page,omitempty"`
+	Limit    *int          `json:"limit,omitempty"`
 }
 
 type TokenResponse struct {
 	Token  string `json:"token"`
 	Expiry string `json:"expiry"`
 }
+
+type QueryRequest struct {
+	Query     string         `json:"query"`
+	Function  string         `json:"function"`
+	History   []QueryMessage `json:"history,omitempty"`
+	SessionId string         `json:"sessionId,omitempty"`
+}
+
+type QueryResponse struct {
+	Answer    string `json:"answer"`
+	SessionId string `json:"sessionId,omitempty"`
+}
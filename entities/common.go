@@ -34,6 +34,7 @@ type LibraryResponse struct {
 type LibraryItemResponse struct {
 	CreatedAt        time.Time                          `json:"createdAt" bson:"createdAt"`
 	Name             string                             `json:"name" bson:"name"`
+	ShortName        string                             `json:"shortName,omitempty" bson:"shortName"`
 	Description      string                             `json:"description" bson:"description"`
 	Owner            string                             `json:"owner" bson:"owner"`
 	OwnerDescription string                             `json:"ownerDescription" bson:"ownerDescription"`
@@ -0,0 +1,235 @@
+package entities
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ContractChange struct {
+	Path        string
+	Description string
+	Breaking    bool
+}
+
+// DiffRequirements compares the contract of two versions of a function. Changes that would
+// break an existing caller (new required input, removed properties, type changes) are flagged
+// as breaking, anything else is additive.
+func DiffRequirements(from FunctionRequirement, to FunctionRequirement) ([]ContractChange, error) {
+	var changes []ContractChange
+
+	if !strings.EqualFold(from.RequestType, to.RequestType) {
+		changes = append(changes, ContractChange{Path: "requestType", Description: fmt.Sprintf("changed from %s to %s", from.RequestType, to.RequestType), Breaking: true})
+	}
+	if stringValue(from.InputType) != stringValue(to.InputType) {
+		changes = append(changes, ContractChange{Path: "inputType", Description: fmt.Sprintf("changed from %s to %s", displayValue(from.InputType), displayValue(to.InputType)), Breaking: true})
+	}
+	if stringValue(from.OutputType) != stringValue(to.OutputType) {
+		changes = append(changes, ContractChange{Path: "outputType", Description: fmt.Sprintf("changed from %s to %s", displayValue(from.OutputType), displayValue(to.OutputType)), Breaking: true})
+	}
+
+	changes = append(changes, diffQueryParams(from.QueryParams, to.QueryParams)...)
+
+	fromInput, err := DecodeJsonSchema(from.InputJsonSchemaEncoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode input schema: %v", err)
+	}
+	toInput, err := DecodeJsonSchema(to.InputJsonSchemaEncoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode input schema: %v", err)
+	}
+	changes = append(changes, diffSchema("input", fromInput, toInput, true)...)
+
+	fromOutput, err := DecodeJsonSchema(from.OutputJsonSchemaEncoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode output schema: %v", err)
+	}
+	toOutput, err := DecodeJsonSchema(to.OutputJsonSchemaEncoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode output schema: %v", err)
+	}
+	changes = append(changes, diffSchema("output", fromOutput, toOutput, false)...)
+
+	return changes, nil
+}
+
+// DecodeJsonSchema decodes a base64 encoded JSON schema, a nil or empty schema returns nil.
+func DecodeJsonSchema(encoded *string) (map[string]interface{}, error) {
+	if encoded == nil || *encoded == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(*encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(decoded, &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func diffQueryParams(from []*QueryParam, to []*QueryParam) []ContractChange {
+	var changes []ContractChange
+
+	fromParams := map[string]*QueryParam{}
+	for _, p := range from {
+		fromParams[p.Name] = p
+	}
+	toParams := map[string]*QueryParam{}
+	for _, p := range to {
+		toParams[p.Name] = p
+	}
+
+	for _, p := range to {
+		old, ok := fromParams[p.Name]
+		path := "query/" + p.Name
+		switch {
+		case !ok && p.Required:
+			changes = append(changes, ContractChange{Path: path, Description: "new required query parameter", Breaking: true})
+		case !ok:
+			changes = append(changes, ContractChange{Path: path, Description: "new optional query parameter"})
+		case !old.Required && p.Required:
+			changes = append(changes, ContractChange{Path: path, Description: "query parameter is now required", Breaking: true})
+		case old.Required && !p.Required:
+			changes = append(changes, ContractChange{Path: path, Description: "query parameter is now optional"})
+		}
+	}
+
+	for _, p := range from {
+		if _, ok := toParams[p.Name]; !ok {
+			changes = append(changes, ContractChange{Path: "query/" + p.Name, Description: "query parameter removed", Breaking: true})
+		}
+	}
+
+	return changes
+}
+
+// diffSchema walks both schemas comparing types, properties and required fields. For an input
+// schema a newly required field breaks callers, for an output schema a field that is no longer
+// guaranteed does.
+func diffSchema(path string, from map[string]interface{}, to map[string]interface{}, input bool) []ContractChange {
+	var changes []ContractChange
+
+	if from == nil && to == nil {
+		return changes
+	}
+	if from == nil {
+		return append(changes, ContractChange{Path: path, Description: "schema added"})
+	}
+	if to == nil {
+		return append(changes, ContractChange{Path: path, Description: "schema removed", Breaking: true})
+	}
+
+	fromType := schemaType(from)
+	toType := schemaType(to)
+	if fromType != "" && toType != "" && fromType != toType {
+		return append(changes, ContractChange{Path: path, Description: fmt.Sprintf("type changed from %s to %s", fromType, toType), Breaking: true})
+	}
+
+	fromProps := schemaProperties(from)
+	toProps := schemaProperties(to)
+	fromRequired := schemaRequired(from)
+	toRequired := schemaRequired(to)
+
+	for _, name := range sortedKeys(toProps) {
+		propPath := path + "/" + name
+		if _, ok := fromProps[name]; !ok {
+			if input && toRequired[name] {
+				changes = append(changes, ContractChange{Path: propPath, Description: "new required field", Breaking: true})
+			} else {
+				changes = append(changes, ContractChange{Path: propPath, Description: "new field"})
+			}
+			continue
+		}
+
+		changes = append(changes, diffSchema(propPath, fromProps[name], toProps[name], input)...)
+
+		switch {
+		case !fromRequired[name] && toRequired[name]:
+			changes = append(changes, ContractChange{Path: propPath, Description: "field is now required", Breaking: input})
+		case fromRequired[name] && !toRequired[name]:
+			changes = append(changes, ContractChange{Path: propPath, Description: "field is no longer required", Breaking: !input})
+		}
+	}
+
+	for _, name := range sortedKeys(fromProps) {
+		if _, ok := toProps[name]; !ok {
+			changes = append(changes, ContractChange{Path: path + "/" + name, Description: "field removed", Breaking: true})
+		}
+	}
+
+	fromItems, _ := from["items"].(map[string]interface{})
+	toItems, _ := to["items"].(map[string]interface{})
+	if fromItems != nil || toItems != nil {
+		changes = append(changes, diffSchema(path+"/[]", fromItems, toItems, input)...)
+	}
+
+	return changes
+}
+
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			types = append(types, fmt.Sprintf("%v", v))
+		}
+		sort.Strings(types)
+		return strings.Join(types, "|")
+	}
+	return ""
+}
+
+func schemaProperties(schema map[string]interface{}) map[string]map[string]interface{} {
+	props := map[string]map[string]interface{}{}
+	raw, _ := schema["properties"].(map[string]interface{})
+	for name, v := range raw {
+		if prop, ok := v.(map[string]interface{}); ok {
+			props[name] = prop
+		} else {
+			props[name] = map[string]interface{}{}
+		}
+	}
+	return props
+}
+
+func schemaRequired(schema map[string]interface{}) map[string]bool {
+	required := map[string]bool{}
+	raw, _ := schema["required"].([]interface{})
+	for _, v := range raw {
+		if name, ok := v.(string); ok {
+			required[name] = true
+		}
+	}
+	return required
+}
+
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.ToUpper(*s)
+}
+
+func displayValue(s *string) string {
+	if s == nil || *s == "" {
+		return "NONE"
+	}
+	return *s
+}
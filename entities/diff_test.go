@@ -0,0 +1,145 @@
+package entities
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func encodeSchema(text string) *string {
+	if text == "" {
+		return nil
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	return &encoded
+}
+
+func TestDiffRequirements(t *testing.T) {
+	file := "FILE"
+	json := "JSON"
+
+	tests := []struct {
+		name string
+		from FunctionRequirement
+		to   FunctionRequirement
+		want []ContractChange
+	}{
+		{
+			name: "no changes",
+			from: FunctionRequirement{RequestType: "POST", InputJsonSchemaEncoded: encodeSchema(`{"type":"object"}`)},
+			to:   FunctionRequirement{RequestType: "post", InputJsonSchemaEncoded: encodeSchema(`{"type":"object"}`)},
+		},
+		{
+			name: "request and io types",
+			from: FunctionRequirement{RequestType: "GET", InputType: &json},
+			to:   FunctionRequirement{RequestType: "POST", InputType: &file},
+			want: []ContractChange{
+				{Path: "requestType", Description: "changed from GET to POST", Breaking: true},
+				{Path: "inputType", Description: "changed from JSON to FILE", Breaking: true},
+			},
+		},
+		{
+			name: "query params",
+			from: FunctionRequirement{QueryParams: []*QueryParam{{Name: "a"}, {Name: "b", Required: true}, {Name: "gone"}}},
+			to:   FunctionRequirement{QueryParams: []*QueryParam{{Name: "a", Required: true}, {Name: "b"}, {Name: "new", Required: true}, {Name: "opt"}}},
+			want: []ContractChange{
+				{Path: "query/a", Description: "query parameter is now required", Breaking: true},
+				{Path: "query/b", Description: "query parameter is now optional"},
+				{Path: "query/new", Description: "new required query parameter", Breaking: true},
+				{Path: "query/opt", Description: "new optional query parameter"},
+				{Path: "query/gone", Description: "query parameter removed", Breaking: true},
+			},
+		},
+		{
+			name: "input fields",
+			from: FunctionRequirement{InputJsonSchemaEncoded: encodeSchema(`{"type":"object","required":["a"],
+				"properties":{"a":{"type":"string"},"b":{"type":"string"},"gone":{}}}`)},
+			to: FunctionRequirement{InputJsonSchemaEncoded: encodeSchema(`{"type":"object","required":["b","c"],
+				"properties":{"a":{"type":"string"},"b":{"type":"number"},"c":{},"d":{}}}`)},
+			want: []ContractChange{
+				{Path: "input/a", Description: "field is no longer required"},
+				{Path: "input/b", Description: "type changed from string to number", Breaking: true},
+				{Path: "input/b", Description: "field is now required", Breaking: true},
+				{Path: "input/c", Description: "new required field", Breaking: true},
+				{Path: "input/d", Description: "new field"},
+				{Path: "input/gone", Description: "field removed", Breaking: true},
+			},
+		},
+		{
+			name: "output guarantees",
+			from: FunctionRequirement{OutputJsonSchemaEncoded: encodeSchema(`{"type":"object","required":["temp"],
+				"properties":{"temp":{"type":"number"}}}`)},
+			to: FunctionRequirement{OutputJsonSchemaEncoded: encodeSchema(`{"type":"object",
+				"properties":{"temp":{"type":"number"},"extra":{}}}`)},
+			want: []ContractChange{
+				{Path: "output/extra", Description: "new field"},
+				{Path: "output/temp", Description: "field is no longer required", Breaking: true},
+			},
+		},
+		{
+			name: "nested objects and arrays",
+			from: FunctionRequirement{InputJsonSchemaEncoded: encodeSchema(`{"type":"object","properties":{
+				"loc":{"type":"object","properties":{"lat":{"type":"number"}}},
+				"tags":{"type":"array","items":{"type":"string"}}}}`)},
+			to: FunctionRequirement{InputJsonSchemaEncoded: encodeSchema(`{"type":"object","properties":{
+				"loc":{"type":"object","properties":{}},
+				"tags":{"type":"array","items":{"type":"integer"}}}}`)},
+			want: []ContractChange{
+				{Path: "input/loc/lat", Description: "field removed", Breaking: true},
+				{Path: "input/tags/[]", Description: "type changed from string to integer", Breaking: true},
+			},
+		},
+		{
+			name: "schema added and removed",
+			from: FunctionRequirement{OutputJsonSchemaEncoded: encodeSchema(`{"type":"object"}`)},
+			to:   FunctionRequirement{InputJsonSchemaEncoded: encodeSchema(`{"type":"object"}`)},
+			want: []ContractChange{
+				{Path: "input", Description: "schema added"},
+				{Path: "output", Description: "schema removed", Breaking: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffRequirements(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJsonSchema(t *testing.T) {
+	bad := "not base64!"
+	notJson := base64.StdEncoding.EncodeToString([]byte("{"))
+	empty := ""
+
+	tests := []struct {
+		name    string
+		encoded *string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "nil", encoded: nil, wantNil: true},
+		{name: "empty", encoded: &empty, wantNil: true},
+		{name: "bad base64", encoded: &bad, wantErr: true},
+		{name: "bad json", encoded: &notJson, wantErr: true},
+		{name: "valid", encoded: encodeSchema(`{"type":"object"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := DecodeJsonSchema(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (schema == nil) != tt.wantNil {
+				t.Errorf("schema = %v, wantNil %v", schema, tt.wantNil)
+			}
+		})
+	}
+}
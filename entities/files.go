@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodeFileSchema reads a file schema from either its JSON or its base64 encoded form.
+func DecodeFileSchema(raw *json.RawMessage, encoded *string) (*FileSchema, error) {
+	var data []byte
+
+	switch {
+	case raw != nil && len(*raw) > 0:
+		data = *raw
+	case encoded != nil && *encoded != "":
+		decoded, err := base64.StdEncoding.DecodeString(*encoded)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	default:
+		return nil, nil
+	}
+
+	var schema FileSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// CheckFileExtension makes sure the file has one of the extensions allowed by the schema,
+// a schema without extensions allows anything.
+func CheckFileExtension(path string, schema *FileSchema) error {
+	if schema == nil || len(schema.Extensions) == 0 {
+		return nil
+	}
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	for _, allowed := range schema.Extensions {
+		if ext == strings.TrimPrefix(strings.ToLower(allowed), ".") {
+			return nil
+		}
+	}
+	return fmt.Errorf("file extension %q is not allowed, expected one of: %s", ext, strings.Join(schema.Extensions, ", "))
+}
+
+// IsTextContent is true when a response body is safe to print to a terminal.
+func IsTextContent(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	contentType = strings.ToLower(contentType)
+	for _, text := range []string{"json", "text/", "xml", "javascript", "yaml", "x-www-form-urlencoded"} {
+		if strings.Contains(contentType, text) {
+			return utf8.Valid(body)
+		}
+	}
+	return false
+}
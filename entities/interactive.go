@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaField is one top-level property of an input schema, with what is needed to prompt for it
+type SchemaField struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Example     interface{}
+	HasExample  bool
+	Schema      map[string]interface{}
+}
+
+// SchemaFields lists the top-level properties of a schema, required ones first. The example
+// for each comes from the example body when it has one, then the schema's default or examples.
+func SchemaFields(schema map[string]interface{}, example []byte) []SchemaField {
+	var exampleBody map[string]interface{}
+	if len(example) > 0 {
+		_ = json.Unmarshal(example, &exampleBody)
+	}
+
+	props := schemaProperties(schema)
+	required := schemaRequired(schema)
+
+	var fields []SchemaField
+	for _, name := range sortedKeys(props) {
+		prop := props[name]
+		field := SchemaField{Name: name, Required: required[name], Schema: prop}
+		field.Type = strings.Join(schemaTypes(prop), "|")
+		field.Description, _ = prop["description"].(string)
+
+		if v, ok := exampleBody[name]; ok {
+			field.Example, field.HasExample = v, true
+		} else if v, ok := prop["default"]; ok {
+			field.Example, field.HasExample = v, true
+		} else if v, ok := prop["examples"].([]interface{}); ok && len(v) > 0 {
+			field.Example, field.HasExample = v[0], true
+		} else if v, ok := prop["example"]; ok {
+			field.Example, field.HasExample = v, true
+		}
+		fields = append(fields, field)
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Required && !fields[j].Required
+	})
+	return fields
+}
+
+// ExampleText is the example as it would be typed in, strings bare and anything else as JSON
+func (f SchemaField) ExampleText() string {
+	if !f.HasExample {
+		return ""
+	}
+	if s, ok := f.Example.(string); ok {
+		return s
+	}
+	text, err := json.Marshal(f.Example)
+	if err != nil {
+		return ""
+	}
+	return string(text)
+}
+
+// ParseFieldValue turns what was typed for a field into a value and checks it against the
+// field's schema. Anything that can be a string takes the text as is, unless it reads as JSON
+// of one of the field's other types (null for a string|null field). Anything else is read as JSON.
+func ParseFieldValue(f SchemaField, input string) (interface{}, error) {
+	types := schemaTypes(f.Schema)
+
+	takesString := len(types) == 0
+	for _, t := range types {
+		if t == "string" {
+			takesString = true
+		}
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	decodeErr := dec.Decode(&value)
+	if decodeErr == nil && dec.More() {
+		decodeErr = fmt.Errorf("unexpected text after the value")
+	}
+
+	switch {
+	case takesString && (decodeErr != nil || jsonValueIs(value, "string") || !matchesAnyType(value, types)):
+		value = input
+	case decodeErr != nil:
+		return nil, fmt.Errorf("expected %s, enter it as JSON", strings.Join(types, " or "))
+	}
+
+	var errs []ValidationError
+	validateValue(f.Schema, value, "", &errs)
+	if len(errs) > 0 {
+		var messages []string
+		for _, e := range errs {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	return value, nil
+}
@@ -0,0 +1,91 @@
+package entities
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseFieldValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "plain string", schema: `{"type":"string"}`, input: "London", want: "London"},
+		{name: "number typed into a string", schema: `{"type":"string"}`, input: "123", want: "123"},
+		{name: "quoted string is kept as typed", schema: `{"type":"string"}`, input: `"hi"`, want: `"hi"`},
+		{name: "null typed into a string", schema: `{"type":"string"}`, input: "null", want: "null"},
+		{name: "null for string or null", schema: `{"type":["string","null"]}`, input: "null", want: nil},
+		{name: "plain text for string or null", schema: `{"type":["string","null"]}`, input: "Paris", want: "Paris"},
+		{name: "number for string or null", schema: `{"type":["string","null"]}`, input: "42", want: "42"},
+		{name: "number for string or number", schema: `{"type":["string","number"]}`, input: "42", want: json.Number("42")},
+		{name: "untyped json", schema: `{}`, input: `{"a":1}`, want: map[string]interface{}{"a": json.Number("1")}},
+		{name: "untyped text", schema: `{}`, input: "hello there", want: "hello there"},
+		{name: "integer", schema: `{"type":"integer"}`, input: "3", want: json.Number("3")},
+		{name: "integer rejects fractions", schema: `{"type":"integer"}`, input: "3.5", wantErr: true},
+		{name: "number below minimum", schema: `{"type":"number","minimum":10}`, input: "5", wantErr: true},
+		{name: "string breaks the schema", schema: `{"type":"string","maxLength":2}`, input: "long", wantErr: true},
+		{name: "not json for a number", schema: `{"type":"number"}`, input: "five", wantErr: true},
+		{name: "trailing text for a number", schema: `{"type":"number"}`, input: "5 apples", wantErr: true},
+		{name: "array", schema: `{"type":"array","items":{"type":"string"}}`, input: `["a","b"]`, want: []interface{}{"a", "b"}},
+		{name: "boolean", schema: `{"type":"boolean"}`, input: "true", want: true},
+		{name: "enum string", schema: `{"type":"string","enum":["c","f"]}`, input: "k", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := SchemaField{Name: "field", Schema: mustSchema(t, tt.schema)}
+
+			got, err := ParseFieldValue(field, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got (%v, %v), wantErr %v", got, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldValueNamesTheExpectedTypes(t *testing.T) {
+	field := SchemaField{Name: "count", Schema: mustSchema(t, `{"type":["integer","boolean"]}`)}
+
+	_, err := ParseFieldValue(field, "lots")
+	if err == nil || err.Error() != "expected integer or boolean, enter it as JSON" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSchemaFields(t *testing.T) {
+	schema := mustSchema(t, `{"type":"object","required":["city"],"properties":{
+		"units":{"type":"string","default":"c"},
+		"city":{"type":"string","description":"City name"},
+		"days":{"type":["integer","null"],"examples":[3]},
+		"extra":{}}}`)
+
+	fields := SchemaFields(schema, []byte(`{"city":"London"}`))
+
+	type summary struct {
+		Name     string
+		Type     string
+		Required bool
+		Example  string
+	}
+	var got []summary
+	for _, f := range fields {
+		got = append(got, summary{f.Name, f.Type, f.Required, f.ExampleText()})
+	}
+
+	want := []summary{
+		{"city", "string", true, "London"},
+		{"days", "integer|null", false, "3"},
+		{"extra", "", false, ""},
+		{"units", "string", false, "c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package entities
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseQueryArgs turns repeated key=value arguments into a map of query parameters.
+func ParseQueryArgs(args []string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid query parameter %q, expected key=value", arg)
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+// ReadRequestBody reads the request body from the --data value: inline JSON, @file to read a
+// file, or - (or @-) to read stdin. An empty value means no body.
+func ReadRequestBody(data string, stdin io.Reader) ([]byte, error) {
+	var body []byte
+	var err error
+
+	switch {
+	case data == "":
+		return nil, nil
+	case data == "-" || data == "@-":
+		body, err = io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body from stdin: %v", err)
+		}
+	case strings.HasPrefix(data, "@"):
+		body, err = os.ReadFile(strings.TrimPrefix(data, "@"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body file: %v", err)
+		}
+	default:
+		body = []byte(data)
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && !json.Valid(body) {
+		return nil, fmt.Errorf("request body is not valid JSON")
+	}
+	return body, nil
+}
+
+// PrettyBody indents a JSON body, anything that is not JSON is returned unchanged.
+func PrettyBody(body []byte) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return string(body)
+	}
+	return out.String()
+}
+
+// SideBySide lays two blocks of text out in columns with a heading over each, wrapping lines
+// that are wider than a column.
+func SideBySide(leftTitle string, left string, rightTitle string, right string, width int) string {
+	column := (width - 3) / 2
+
+	leftLines := append([]string{leftTitle, strings.Repeat("-", column)}, wrapColumn(left, column)...)
+	rightLines := append([]string{rightTitle, strings.Repeat("-", column)}, wrapColumn(right, column)...)
+
+	rows := len(leftLines)
+	if len(rightLines) > rows {
+		rows = len(rightLines)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < rows; i++ {
+		l, r := "", ""
+		if i < len(leftLines) {
+			l = leftLines[i]
+		}
+		if i < len(rightLines) {
+			r = rightLines[i]
+		}
+		padding := column - utf8.RuneCountInString(l)
+		sb.WriteString(strings.TrimRight(l+strings.Repeat(" ", padding)+" | "+r, " ") + "\n")
+	}
+	return sb.String()
+}
+
+func wrapColumn(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		runes := []rune(strings.ReplaceAll(line, "\t", "  "))
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
@@ -0,0 +1,143 @@
+package entities
+
+import (
+	"sort"
+	"strings"
+)
+
+// Search weights, a hit in the name counts for more than a hit in the description.
+const (
+	searchNameWeight        = 5
+	searchTagWeight         = 3
+	searchOwnerWeight       = 2
+	searchDescriptionWeight = 1
+)
+
+type LibrarySearchFilter struct {
+	Tags      []string
+	Owner     string
+	Ai        bool
+	Published bool
+}
+
+type LibrarySearchResult struct {
+	Item  LibraryItemResponse
+	Score int
+}
+
+type LibraryTagCount struct {
+	Tag   string
+	Count int
+}
+
+// SearchLibrary ranks the library items against the search terms, items that fail the filter
+// or do not match any term are dropped. With no terms every item passing the filter is returned.
+func SearchLibrary(items []LibraryItemResponse, terms []string, filter LibrarySearchFilter) []LibrarySearchResult {
+	var results []LibrarySearchResult
+
+	for _, item := range items {
+		if !matchesLibraryFilter(item, filter) {
+			continue
+		}
+
+		score := 0
+		for _, term := range terms {
+			score += scoreLibraryItem(item, strings.ToLower(term))
+		}
+
+		if len(terms) > 0 && score == 0 {
+			continue
+		}
+		results = append(results, LibrarySearchResult{Item: item, Score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToLower(results[i].Item.Name) < strings.ToLower(results[j].Item.Name)
+	})
+
+	return results
+}
+
+// CountLibraryTags counts the items carrying each tag, including unique tags with no items.
+func CountLibraryTags(response LibraryResponse) []LibraryTagCount {
+	counts := map[string]int{}
+	for _, tag := range response.UniqueTags {
+		counts[tag] = 0
+	}
+	for _, item := range response.LibraryItem {
+		for _, tag := range item.Tags {
+			counts[tag]++
+		}
+	}
+
+	var tags []LibraryTagCount
+	for tag, count := range counts {
+		tags = append(tags, LibraryTagCount{Tag: tag, Count: count})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	return tags
+}
+
+func matchesLibraryFilter(item LibraryItemResponse, filter LibrarySearchFilter) bool {
+	if filter.Owner != "" && !strings.EqualFold(item.Owner, filter.Owner) {
+		return false
+	}
+	if filter.Ai && !item.Ai {
+		return false
+	}
+	if filter.Published && (item.Published == nil || !*item.Published) {
+		return false
+	}
+
+	for _, wanted := range filter.Tags {
+		found := false
+		for _, tag := range item.Tags {
+			if strings.EqualFold(tag, wanted) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func scoreLibraryItem(item LibraryItemResponse, term string) int {
+	if term == "" {
+		return 0
+	}
+
+	score := 0
+	name := strings.ToLower(item.Name)
+	if name == term {
+		score += searchNameWeight * 2
+	} else if strings.Contains(name, term) {
+		score += searchNameWeight
+	}
+
+	for _, tag := range item.Tags {
+		if strings.Contains(strings.ToLower(tag), term) {
+			score += searchTagWeight
+		}
+	}
+
+	if strings.Contains(strings.ToLower(item.Owner), term) {
+		score += searchOwnerWeight
+	}
+
+	score += strings.Count(strings.ToLower(item.Description), term) * searchDescriptionWeight
+
+	return score
+}
@@ -0,0 +1,61 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type AmbiguousFunctionError struct {
+	Ref     string
+	Matches []LibraryItemResponse
+}
+
+func (e *AmbiguousFunctionError) Error() string {
+	var candidates []string
+	for _, m := range e.Matches {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", m.FunctionId, m.Name))
+	}
+	sort.Strings(candidates)
+	return fmt.Sprintf("%q is ambiguous, it matches: %s", e.Ref, strings.Join(candidates, ", "))
+}
+
+// ResolveFunction finds the library item a user means by ref, which can be a function ID, the
+// display name, the jellyspec short name or a unique prefix of any of those. Exact matches win
+// over prefixes, and at each step an ID wins over a name and a name over a short name. Names
+// and short names ignore case. Returns nil if nothing matches.
+func ResolveFunction(items []LibraryItemResponse, ref string) (*LibraryItemResponse, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	lower := strings.ToLower(ref)
+
+	matchers := []func(LibraryItemResponse) bool{
+		func(i LibraryItemResponse) bool { return i.FunctionId == ref },
+		func(i LibraryItemResponse) bool { return strings.ToLower(i.Name) == lower },
+		func(i LibraryItemResponse) bool { return i.ShortName != "" && strings.ToLower(i.ShortName) == lower },
+		func(i LibraryItemResponse) bool { return strings.HasPrefix(i.FunctionId, ref) },
+		func(i LibraryItemResponse) bool { return strings.HasPrefix(strings.ToLower(i.Name), lower) },
+		func(i LibraryItemResponse) bool {
+			return i.ShortName != "" && strings.HasPrefix(strings.ToLower(i.ShortName), lower)
+		},
+	}
+
+	for _, match := range matchers {
+		var matches []LibraryItemResponse
+		for _, item := range items {
+			if match(item) {
+				matches = append(matches, item)
+			}
+		}
+
+		if len(matches) == 1 {
+			return &matches[0], nil
+		}
+		if len(matches) > 1 {
+			return nil, &AmbiguousFunctionError{Ref: ref, Matches: matches}
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveFunction(t *testing.T) {
+	items := []LibraryItemResponse{
+		{FunctionId: "abc123", Name: "WeatherCompare", ShortName: "wcompare"},
+		{FunctionId: "abd456", Name: "Weather", ShortName: "wx"},
+		{FunctionId: "wx9999", Name: "Translate", ShortName: "tr"},
+		{FunctionId: "fff000", Name: "abc", ShortName: "summary"},
+		{FunctionId: "eee111", Name: "Summarise"},
+	}
+
+	tests := []struct {
+		name          string
+		ref           string
+		want          string
+		wantAmbiguous int
+	}{
+		{name: "exact id", ref: "abc123", want: "abc123"},
+		{name: "exact name ignores case", ref: "weathercompare", want: "abc123"},
+		{name: "exact name over a longer name with that prefix", ref: "Weather", want: "abd456"},
+		{name: "exact name over an id prefix", ref: "abc", want: "fff000"},
+		{name: "exact short name", ref: "WCOMPARE", want: "abc123"},
+		{name: "exact short name over an id prefix", ref: "wx", want: "abd456"},
+		{name: "id prefix", ref: "abd", want: "abd456"},
+		{name: "name prefix", ref: "trans", want: "wx9999"},
+		{name: "name prefix over a short name prefix", ref: "summ", want: "eee111"},
+		{name: "short name prefix only", ref: "wcomp", want: "abc123"},
+		{name: "ambiguous id prefix", ref: "ab", wantAmbiguous: 2},
+		{name: "ambiguous name prefix", ref: "weath", wantAmbiguous: 2},
+		{name: "no match", ref: "nothing", want: ""},
+		{name: "empty ref", ref: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveFunction(items, tt.ref)
+
+			if tt.wantAmbiguous > 0 {
+				var ambiguous *AmbiguousFunctionError
+				if !errors.As(err, &ambiguous) {
+					t.Fatalf("got (%v, %v), want an ambiguous match", got, err)
+				}
+				if len(ambiguous.Matches) != tt.wantAmbiguous {
+					t.Errorf("got %d candidates, want %d", len(ambiguous.Matches), tt.wantAmbiguous)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			id := ""
+			if got != nil {
+				id = got.FunctionId
+			}
+			if id != tt.want {
+				t.Errorf("got %q, want %q", id, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmbiguousFunctionErrorListsCandidates(t *testing.T) {
+	err := &AmbiguousFunctionError{Ref: "w", Matches: []LibraryItemResponse{
+		{FunctionId: "b", Name: "Second"},
+		{FunctionId: "a", Name: "First"},
+	}}
+
+	want := `"w" is ambiguous, it matches: a (First), b (Second)`
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sizeOrder ranks the size names the platform uses, smallest first.
+var sizeOrder = []string{"xxs", "xs", "xsmall", "extrasmall", "s", "small", "m", "medium", "l", "large", "xl", "xlarge", "extralarge", "xxl", "xxlarge"}
+
+// SelectSize picks the named size, or the smallest size when no name is given. An unknown
+// name returns an error listing the valid sizes.
+func SelectSize(sizes []VersionDetailsResponseSizes, name string) (*VersionDetailsResponseSizes, error) {
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no sizes are deployed")
+	}
+
+	if name == "" {
+		ordered := SortSizes(sizes)
+		return &ordered[0], nil
+	}
+
+	for i := range sizes {
+		if strings.EqualFold(sizes[i].Size, name) {
+			return &sizes[i], nil
+		}
+	}
+
+	var valid []string
+	for _, s := range SortSizes(sizes) {
+		valid = append(valid, s.Size)
+	}
+	return nil, fmt.Errorf("size %q does not exist, valid sizes are: %s", name, strings.Join(valid, ", "))
+}
+
+// SortSizes returns the sizes smallest first, sizes with unknown names keep their order at the end.
+func SortSizes(sizes []VersionDetailsResponseSizes) []VersionDetailsResponseSizes {
+	ordered := append([]VersionDetailsResponseSizes{}, sizes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return sizeRank(ordered[i].Size) < sizeRank(ordered[j].Size)
+	})
+	return ordered
+}
+
+func sizeRank(size string) int {
+	normalised := strings.ReplaceAll(strings.ToLower(size), "-", "")
+	normalised = strings.ReplaceAll(normalised, "_", "")
+	for i, s := range sizeOrder {
+		if normalised == s {
+			return i
+		}
+	}
+	return len(sizeOrder)
+}
@@ -0,0 +1,241 @@
+package entities
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const SnippetTokenPlaceholder = "YOUR_JELLYFAAS_TOKEN"
+
+const snippetFilePlaceholder = "path/to/input"
+
+// SnippetRequest is everything a snippet needs to call a function
+type SnippetRequest struct {
+	Method      string
+	Url         string
+	Query       map[string]string
+	Body        []byte
+	FileInput   bool
+	TokenHeader string
+	Token       string
+}
+
+// Snippet generates ready to run code that calls a function, in one of the formats curl,
+// httpie, python-requests, js-fetch or go.
+func Snippet(format string, r SnippetRequest) (string, error) {
+	if r.Token == "" {
+		r.Token = SnippetTokenPlaceholder
+	}
+
+	if len(r.Body) > 0 {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, r.Body); err != nil {
+			return "", fmt.Errorf("example body is not valid JSON: %v", err)
+		}
+		r.Body = compact.Bytes()
+	}
+
+	switch format {
+	case "curl":
+		return curlSnippet(r), nil
+	case "httpie":
+		return httpieSnippet(r), nil
+	case "python-requests":
+		return pythonSnippet(r), nil
+	case "js-fetch":
+		return jsSnippet(r), nil
+	case "go":
+		return goSnippet(r), nil
+	}
+	return "", fmt.Errorf("unknown snippet format %q", format)
+}
+
+func (r SnippetRequest) fullUrl() string {
+	if len(r.Query) == 0 {
+		return r.Url
+	}
+	values := url.Values{}
+	for name, value := range r.Query {
+		values.Set(name, value)
+	}
+	return r.Url + "?" + values.Encode()
+}
+
+func (r SnippetRequest) queryNames() []string {
+	var names []string
+	for name := range r.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func curlSnippet(r SnippetRequest) string {
+	lines := []string{fmt.Sprintf("curl -X %s %s", r.Method, shellQuote(r.fullUrl())),
+		"  -H " + shellQuote(r.TokenHeader+": "+r.Token)}
+
+	if r.FileInput {
+		lines = append(lines, "  -F "+shellQuote("file=@"+snippetFilePlaceholder))
+	} else if len(r.Body) > 0 {
+		lines = append(lines, "  -H 'Content-Type: application/json'", "  -d "+shellQuote(string(r.Body)))
+	}
+	return strings.Join(lines, " \\\n") + "\n"
+}
+
+func httpieSnippet(r SnippetRequest) string {
+	var args []string
+	if r.FileInput {
+		args = append(args, "--form")
+	}
+	args = append(args, r.Method, shellQuote(r.Url))
+	for _, name := range r.queryNames() {
+		args = append(args, shellQuote(name+"=="+r.Query[name]))
+	}
+	args = append(args, shellQuote(r.TokenHeader+":"+r.Token))
+	if r.FileInput {
+		args = append(args, shellQuote("file@"+snippetFilePlaceholder))
+	}
+
+	command := "http " + strings.Join(args, " ")
+	if !r.FileInput && len(r.Body) > 0 {
+		command = "echo " + shellQuote(string(r.Body)) + " | " + command
+	}
+	return command + "\n"
+}
+
+func pythonSnippet(r SnippetRequest) string {
+	var sb strings.Builder
+	sb.WriteString("import requests\n\n")
+
+	if r.FileInput {
+		sb.WriteString(fmt.Sprintf("with open(%s, \"rb\") as f:\n", strconv.Quote(snippetFilePlaceholder)))
+		sb.WriteString("    files = {\"file\": f.read()}\n\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("response = requests.request(\n    %s,\n    %s,\n", strconv.Quote(r.Method), strconv.Quote(r.Url)))
+	if !r.FileInput && len(r.Body) > 0 {
+		sb.WriteString(fmt.Sprintf("    headers={%s: %s, \"Content-Type\": \"application/json\"},\n", strconv.Quote(r.TokenHeader), strconv.Quote(r.Token)))
+	} else {
+		sb.WriteString(fmt.Sprintf("    headers={%s: %s},\n", strconv.Quote(r.TokenHeader), strconv.Quote(r.Token)))
+	}
+	if len(r.Query) > 0 {
+		var params []string
+		for _, name := range r.queryNames() {
+			params = append(params, strconv.Quote(name)+": "+strconv.Quote(r.Query[name]))
+		}
+		sb.WriteString(fmt.Sprintf("    params={%s},\n", strings.Join(params, ", ")))
+	}
+	if r.FileInput {
+		sb.WriteString("    files=files,\n")
+	} else if len(r.Body) > 0 {
+		sb.WriteString(fmt.Sprintf("    data=%s,\n", strconv.Quote(string(r.Body))))
+	}
+	sb.WriteString(")\n\nprint(response.status_code)\nprint(response.text)\n")
+	return sb.String()
+}
+
+func jsSnippet(r SnippetRequest) string {
+	var sb strings.Builder
+
+	headers := fmt.Sprintf("%s: %s", strconv.Quote(r.TokenHeader), strconv.Quote(r.Token))
+	body := ""
+	if r.FileInput {
+		sb.WriteString("// file is a File or Blob, for example from an <input type=\"file\">\n")
+		sb.WriteString("const form = new FormData();\nform.append(\"file\", file);\n\n")
+		body = "  body: form,\n"
+	} else if len(r.Body) > 0 {
+		headers += ", \"Content-Type\": \"application/json\""
+		var pretty bytes.Buffer
+		_ = json.Indent(&pretty, r.Body, "  ", "  ")
+		body = fmt.Sprintf("  body: JSON.stringify(%s),\n", pretty.String())
+	}
+
+	sb.WriteString(fmt.Sprintf("const response = await fetch(%s, {\n", strconv.Quote(r.fullUrl())))
+	sb.WriteString(fmt.Sprintf("  method: %s,\n", strconv.Quote(r.Method)))
+	sb.WriteString(fmt.Sprintf("  headers: { %s },\n", headers))
+	sb.WriteString(body)
+	sb.WriteString("});\n\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return sb.String()
+}
+
+func goSnippet(r SnippetRequest) string {
+	var imports []string
+	var setup string
+	bodyArg := "nil"
+	contentType := ""
+
+	switch {
+	case r.FileInput:
+		imports = []string{"bytes", "fmt", "io", "mime/multipart", "net/http", "os"}
+		setup = fmt.Sprintf(`	f, err := os.Open(%s)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", f.Name())
+	if err != nil {
+		panic(err)
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		panic(err)
+	}
+	form.Close()
+
+`, strconv.Quote(snippetFilePlaceholder))
+		bodyArg = "&body"
+		contentType = "form.FormDataContentType()"
+	case len(r.Body) > 0:
+		imports = []string{"fmt", "io", "net/http", "strings"}
+		literal := strconv.Quote(string(r.Body))
+		if strconv.CanBackquote(string(r.Body)) {
+			literal = "`" + string(r.Body) + "`"
+		}
+		setup = fmt.Sprintf("\tbody := strings.NewReader(%s)\n\n", literal)
+		bodyArg = "body"
+		contentType = `"application/json"`
+	default:
+		imports = []string{"fmt", "io", "net/http"}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("package main\n\nimport (\n")
+	for _, i := range imports {
+		sb.WriteString(fmt.Sprintf("\t%s\n", strconv.Quote(i)))
+	}
+	sb.WriteString(")\n\nfunc main() {\n")
+	sb.WriteString(setup)
+	sb.WriteString(fmt.Sprintf("\treq, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(r.Method), strconv.Quote(r.fullUrl()), bodyArg))
+	sb.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	sb.WriteString(fmt.Sprintf("\treq.Header.Set(%s, %s)\n", strconv.Quote(r.TokenHeader), strconv.Quote(r.Token)))
+	if contentType != "" {
+		sb.WriteString(fmt.Sprintf("\treq.Header.Set(\"Content-Type\", %s)\n", contentType))
+	}
+	sb.WriteString(`
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Status)
+	fmt.Println(string(out))
+}
+`)
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// IsEventStream is true when the content type is a server sent event stream.
+func IsEventStream(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "text/event-stream")
+}
+
+// ReadEventStream reads server sent events, calling onData with the data of each event as it
+// arrives. Multi-line data is joined with newlines and the stream ends at EOF or a [DONE] event.
+func ReadEventStream(r io.Reader, onData func(data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data []string
+	flush := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		event := strings.Join(data, "\n")
+		data = nil
+		if event == "[DONE]" {
+			return true, nil
+		}
+		return false, onData(event)
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if line == "" {
+			done, err := flush()
+			if done || err != nil {
+				return err
+			}
+			continue
+		}
+
+		//Comments and the other event fields are not needed
+		field, value, _ := strings.Cut(line, ":")
+		if field != "data" {
+			continue
+		}
+		data = append(data, strings.TrimPrefix(value, " "))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	_, err := flush()
+	return err
+}
+
+// StreamChunkText pulls the text out of one streamed event. Services wrap each token in JSON
+// in different ways, so the common field names are tried and anything else is used as is.
+func StreamChunkText(data string) string {
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return data
+	}
+
+	for _, key := range []string{"answer", "token", "delta", "content", "text"} {
+		switch v := chunk[key].(type) {
+		case string:
+			return v
+		case map[string]interface{}:
+			if s, ok := v["content"].(string); ok {
+				return s
+			}
+			if s, ok := v["text"].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// MarkdownBlocks splits streamed markdown into the blocks that are complete and the text still
+// arriving. A block ends at a blank line outside a code fence, so each one can be rendered on
+// its own without breaking lists or code.
+func MarkdownBlocks(text string) (complete string, pending string) {
+	inFence := false
+	end := 0
+	offset := 0
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		offset += len(line)
+		if !strings.HasSuffix(line, "\n") {
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if trimmed == "" && !inFence {
+			end = offset
+		}
+	}
+
+	return text[:end], text[end:]
+}
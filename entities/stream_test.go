@@ -0,0 +1,128 @@
+package entities
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadEventStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []string
+	}{
+		{
+			name:   "single events",
+			stream: "data: one\n\ndata: two\n\n",
+			want:   []string{"one", "two"},
+		},
+		{
+			name:   "multi-line data is joined",
+			stream: "data: first line\ndata: second line\n\n",
+			want:   []string{"first line\nsecond line"},
+		},
+		{
+			name:   "done ends the stream",
+			stream: "data: one\n\ndata: [DONE]\n\ndata: after\n\n",
+			want:   []string{"one"},
+		},
+		{
+			name:   "comments and other fields are skipped",
+			stream: ": keep-alive\nevent: token\nid: 7\nretry: 100\ndata: hi\n\n",
+			want:   []string{"hi"},
+		},
+		{
+			name:   "crlf line endings",
+			stream: "data: one\r\n\r\ndata: two\r\n\r\n",
+			want:   []string{"one", "two"},
+		},
+		{
+			name:   "only one leading space is removed",
+			stream: "data:  indented\n\ndata:tight\n\n",
+			want:   []string{" indented", "tight"},
+		},
+		{
+			name:   "last event without blank line",
+			stream: "data: one\n\ndata: last",
+			want:   []string{"one", "last"},
+		},
+		{
+			name:   "empty stream",
+			stream: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := ReadEventStream(strings.NewReader(tt.stream), func(data string) error {
+				got = append(got, data)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadEventStreamStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+
+	err := ReadEventStream(strings.NewReader("data: a\n\ndata: b\n\n"), func(string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("got err %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func TestStreamChunkText(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{data: "plain token", want: "plain token"},
+		{data: `{"answer":"a"}`, want: "a"},
+		{data: `{"token":"t"}`, want: "t"},
+		{data: `{"delta":{"content":"d"}}`, want: "d"},
+		{data: `{"delta":{"text":"x"}}`, want: "x"},
+		{data: `{"other":"field"}`, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := StreamChunkText(tt.data); got != tt.want {
+			t.Errorf("StreamChunkText(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownBlocks(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantComplete string
+		wantPending  string
+	}{
+		{name: "no blank line yet", text: "# Title\nmore", wantPending: "# Title\nmore"},
+		{name: "complete block", text: "para one\n\npara two", wantComplete: "para one\n\n", wantPending: "para two"},
+		{name: "blank line inside a code fence", text: "```\na\n\nb\n", wantPending: "```\na\n\nb\n"},
+		{name: "closed code fence", text: "```\na\n\nb\n```\n\nafter", wantComplete: "```\na\n\nb\n```\n\n", wantPending: "after"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			complete, pending := MarkdownBlocks(tt.text)
+			if complete != tt.wantComplete || pending != tt.wantPending {
+				t.Errorf("got (%q, %q), want (%q, %q)", complete, pending, tt.wantComplete, tt.wantPending)
+			}
+		})
+	}
+}
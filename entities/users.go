@@ -0,0 +1,89 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParseDateFilter accepts either a plain date (YYYY-MM-DD) or a full RFC3339 timestamp.
+func ParseDateFilter(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC3339", value)
+	}
+	return &t, nil
+}
+
+// FilterUsers returns the users matching the text filter (name or email, case-insensitive)
+// and created within the optional date range.
+func FilterUsers(users []UserDetails, filter string, after *time.Time, before *time.Time) []UserDetails {
+	filter = strings.ToLower(filter)
+
+	var filtered []UserDetails
+	for _, u := range users {
+		if filter != "" && !strings.Contains(strings.ToLower(u.Name), filter) && !strings.Contains(strings.ToLower(u.Email), filter) {
+			continue
+		}
+		if after != nil && !u.CreatedAt.After(*after) {
+			continue
+		}
+		if before != nil && !u.CreatedAt.Before(*before) {
+			continue
+		}
+		filtered = append(filtered, u)
+	}
+	return filtered
+}
+
+// SortUsers sorts the users in place by name, email, created or updated.
+func SortUsers(users []UserDetails, sortBy string) {
+	switch sortBy {
+	case "name":
+		sort.SliceStable(users, func(i, j int) bool {
+			return strings.ToLower(users[i].Name) < strings.ToLower(users[j].Name)
+		})
+	case "email":
+		sort.SliceStable(users, func(i, j int) bool {
+			return strings.ToLower(users[i].Email) < strings.ToLower(users[j].Email)
+		})
+	case "created":
+		sort.SliceStable(users, func(i, j int) bool {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		})
+	case "updated":
+		sort.SliceStable(users, func(i, j int) bool {
+			return users[i].UpdatedAt.Before(users[j].UpdatedAt)
+		})
+	}
+}
+
+// PageUsers returns the requested page of users (pages start at 1), a limit of 0 returns everything.
+func PageUsers(users []UserDetails, page int, limit int) []UserDetails {
+	if limit <= 0 {
+		return users
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * limit
+	if start >= len(users) {
+		return []UserDetails{}
+	}
+
+	end := start + limit
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[start:end]
+}
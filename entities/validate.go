@@ -0,0 +1,279 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidateJson checks a JSON document against a JSON schema. Only the common keywords are
+// supported (type, properties, required, additionalProperties, items, enum, const and the
+// length, size and range limits), anything else in the schema is ignored.
+func ValidateJson(schema map[string]interface{}, document []byte) ([]ValidationError, error) {
+	var data interface{}
+
+	dec := json.NewDecoder(strings.NewReader(string(document)))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("document is not valid JSON: %v", err)
+	}
+
+	var errs []ValidationError
+	validateValue(schema, data, "", &errs)
+	return errs, nil
+}
+
+// CheckQueryParams reports every required query parameter missing from the query.
+func CheckQueryParams(params []*QueryParam, query map[string]string) []ValidationError {
+	var errs []ValidationError
+	for _, p := range params {
+		if !p.Required {
+			continue
+		}
+		if _, ok := query[p.Name]; !ok {
+			errs = append(errs, ValidationError{Path: "query/" + p.Name, Message: "required query parameter missing"})
+		}
+	}
+	return errs
+}
+
+// SchemaRequiresBody is true when the schema has required properties, so an empty body cannot pass.
+func SchemaRequiresBody(schema map[string]interface{}) bool {
+	return len(schemaRequired(schema)) > 0
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, errs *[]ValidationError) {
+	if schema == nil {
+		return
+	}
+
+	display := path
+	if display == "" {
+		display = "/"
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: display, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema); !matchesAnyType(value, types) {
+		fail("expected %s, got %s", strings.Join(types, " or "), jsonValueType(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value is not one of the allowed values")
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		fail("value must be %v", constant)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, errs)
+	case []interface{}:
+		if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < min {
+			fail("expected at least %v items, got %d", min, len(v))
+		}
+		if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > max {
+			fail("expected at most %v items, got %d", max, len(v))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s/%d", path, i), errs)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := schemaNumber(schema, "minLength"); ok && length < min {
+			fail("expected at least %v characters", min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && length > max {
+			fail("expected at most %v characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("does not match pattern %s", pattern)
+			}
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if min, ok := schemaNumber(schema, "minimum"); ok && n < min {
+			fail("must be >= %v", min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && n > max {
+			fail("must be <= %v", max)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && n <= min {
+			fail("must be > %v", min)
+		}
+		if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && n >= max {
+			fail("must be < %v", max)
+		}
+	}
+}
+
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string, errs *[]ValidationError) {
+	props := schemaProperties(schema)
+
+	var required []string
+	for name := range schemaRequired(schema) {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	for _, name := range required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, ValidationError{Path: path + "/" + name, Message: "required field missing"})
+		}
+	}
+
+	var names []string
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	additional, hasAdditional := schema["additionalProperties"]
+	for _, name := range names {
+		if prop, ok := props[name]; ok {
+			validateValue(prop, object[name], path+"/"+name, errs)
+			continue
+		}
+
+		switch a := additional.(type) {
+		case bool:
+			if hasAdditional && !a {
+				*errs = append(*errs, ValidationError{Path: path + "/" + name, Message: "unexpected field"})
+			}
+		case map[string]interface{}:
+			validateValue(a, object[name], path+"/"+name, errs)
+		}
+	}
+}
+
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	switch n := schema[key].(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jsonValueIs(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return true
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		if jsonValueIs(value, t) {
+			return true
+		}
+	}
+	return len(types) == 0
+}
+
+func jsonValueType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	case json.Number:
+		if jsonValueIs(v, "integer") {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+func jsonEqual(a interface{}, b interface{}) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	//Numbers compare by value, so 1 and 1.0 are the same
+	var l, r interface{}
+	if json.Unmarshal(left, &l) != nil || json.Unmarshal(right, &r) != nil {
+		return false
+	}
+	return fmt.Sprintf("%v", l) == fmt.Sprintf("%v", r)
+}
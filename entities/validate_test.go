@@ -0,0 +1,189 @@
+package entities
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func mustSchema(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &schema); err != nil {
+		t.Fatalf("bad test schema: %v", err)
+	}
+	return schema
+}
+
+func TestValidateJson(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		want     []string
+	}{
+		{
+			name:     "required field present",
+			schema:   `{"type":"object","required":["city"],"properties":{"city":{"type":"string"}}}`,
+			document: `{"city":"London"}`,
+		},
+		{
+			name:     "required field missing",
+			schema:   `{"type":"object","required":["city","days"],"properties":{"city":{"type":"string"}}}`,
+			document: `{}`,
+			want:     []string{"/city: required field missing", "/days: required field missing"},
+		},
+		{
+			name:     "wrong top level type",
+			schema:   `{"type":"object"}`,
+			document: `[1,2]`,
+			want:     []string{"/: expected object, got array"},
+		},
+		{
+			name:     "type union accepts either",
+			schema:   `{"type":"object","properties":{"name":{"type":["string","null"]}}}`,
+			document: `{"name":null}`,
+		},
+		{
+			name:     "type union rejects others",
+			schema:   `{"type":"object","properties":{"name":{"type":["string","null"]}}}`,
+			document: `{"name":5}`,
+			want:     []string{"/name: expected string or null, got integer"},
+		},
+		{
+			name:     "integer rejects fractions",
+			schema:   `{"type":"integer"}`,
+			document: `1.5`,
+			want:     []string{"/: expected integer, got number"},
+		},
+		{
+			name:     "integer accepts whole floats",
+			schema:   `{"type":"integer"}`,
+			document: `2.0`,
+		},
+		{
+			name:     "enum match",
+			schema:   `{"enum":["c","f"]}`,
+			document: `"c"`,
+		},
+		{
+			name:     "enum mismatch",
+			schema:   `{"enum":["c","f"]}`,
+			document: `"k"`,
+			want:     []string{"/: value is not one of the allowed values"},
+		},
+		{
+			name:     "enum compares numbers by value",
+			schema:   `{"enum":[1,2]}`,
+			document: `1.0`,
+		},
+		{
+			name:     "const",
+			schema:   `{"const":"v1"}`,
+			document: `"v2"`,
+			want:     []string{"/: value must be v1"},
+		},
+		{
+			name: "nested objects",
+			schema: `{"type":"object","properties":{"location":{"type":"object","required":["lat"],
+				"properties":{"lat":{"type":"number","minimum":-90,"maximum":90}}}}}`,
+			document: `{"location":{"lat":120}}`,
+			want:     []string{"/location/lat: must be <= 90"},
+		},
+		{
+			name: "nested required missing",
+			schema: `{"type":"object","properties":{"location":{"type":"object","required":["lat","lon"],
+				"properties":{"lat":{"type":"number"},"lon":{"type":"number"}}}}}`,
+			document: `{"location":{"lat":1}}`,
+			want:     []string{"/location/lon: required field missing"},
+		},
+		{
+			name:     "array items and size",
+			schema:   `{"type":"array","minItems":1,"maxItems":2,"items":{"type":"string"}}`,
+			document: `["a",2,"c"]`,
+			want:     []string{"/: expected at most 2 items, got 3", "/1: expected string, got integer"},
+		},
+		{
+			name:     "additional properties forbidden",
+			schema:   `{"type":"object","additionalProperties":false,"properties":{"a":{}}}`,
+			document: `{"a":1,"b":2}`,
+			want:     []string{"/b: unexpected field"},
+		},
+		{
+			name:     "additional properties schema",
+			schema:   `{"type":"object","additionalProperties":{"type":"string"}}`,
+			document: `{"x":"ok","y":true}`,
+			want:     []string{"/y: expected string, got boolean"},
+		},
+		{
+			name:     "string length and pattern",
+			schema:   `{"type":"string","minLength":3,"pattern":"^[a-z]+$"}`,
+			document: `"A"`,
+			want:     []string{"/: expected at least 3 characters", "/: does not match pattern ^[a-z]+$"},
+		},
+		{
+			name:     "exclusive limits",
+			schema:   `{"type":"number","exclusiveMinimum":0,"exclusiveMaximum":10}`,
+			document: `10`,
+			want:     []string{"/: must be < 10"},
+		},
+		{
+			name:     "unknown keywords are ignored",
+			schema:   `{"type":"string","format":"email","$comment":"anything"}`,
+			document: `"not an email"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := ValidateJson(mustSchema(t, tt.schema), []byte(tt.document))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJsonInvalidDocument(t *testing.T) {
+	if _, err := ValidateJson(map[string]interface{}{}, []byte(`{"a":`)); err == nil {
+		t.Error("expected an error for a document that is not JSON")
+	}
+}
+
+func TestCheckQueryParams(t *testing.T) {
+	params := []*QueryParam{
+		{Name: "city", Required: true},
+		{Name: "units", Required: false},
+		{Name: "days", Required: true},
+	}
+
+	tests := []struct {
+		name  string
+		query map[string]string
+		want  []string
+	}{
+		{name: "all present", query: map[string]string{"city": "London", "days": "3"}},
+		{name: "empty value counts as present", query: map[string]string{"city": "", "days": "3"}},
+		{name: "missing required", query: map[string]string{"units": "c"}, want: []string{"query/city: required query parameter missing", "query/days: required query parameter missing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range CheckQueryParams(params, tt.query) {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}